@@ -90,6 +90,40 @@ func BenchmarkSupernet(b *testing.B) {
 	}
 }
 
+func BenchmarkParsePrefixes(b *testing.B) {
+	for _, bb := range []struct {
+		name string
+		lit  string
+	}{
+		{"IPv4", "192.0.2.0/24"},
+		{"IPv6", "2001:db8:cafe:babe::/64"},
+	} {
+		b.Run(bb.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				ipaddr.ParsePrefixes(bb.lit)
+			}
+		})
+	}
+}
+
+func BenchmarkParsePrefixInto(b *testing.B) {
+	for _, bb := range []struct {
+		name string
+		lit  string
+	}{
+		{"IPv4", "192.0.2.0/24"},
+		{"IPv6", "2001:db8:cafe:babe::/64"},
+	} {
+		b.Run(bb.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				p := ipaddr.GetPrefix()
+				ipaddr.ParsePrefixInto(bb.lit, p)
+				ipaddr.PutPrefix(p)
+			}
+		})
+	}
+}
+
 func BenchmarkCursorNext(b *testing.B) {
 	for _, bb := range []struct {
 		name string
@@ -124,6 +158,23 @@ func BenchmarkCursorPrev(b *testing.B) {
 	}
 }
 
+func BenchmarkPrefixAppendString(b *testing.B) {
+	for _, bb := range []struct {
+		name string
+		p    *ipaddr.Prefix
+	}{
+		{"IPv4", toPrefix("192.0.2.0/31")},
+		{"IPv6", toPrefix("2001:db8:cafe:babe::/127")},
+	} {
+		b.Run(bb.name, func(b *testing.B) {
+			buf := make([]byte, 0, 64)
+			for i := 0; i < b.N; i++ {
+				buf = bb.p.AppendString(buf[:0])
+			}
+		})
+	}
+}
+
 func BenchmarkPrefixEqual(b *testing.B) {
 	for _, bb := range []struct {
 		name   string
@@ -156,6 +207,40 @@ func BenchmarkPrefixExclude(b *testing.B) {
 	}
 }
 
+var hostmaskSink []byte
+
+func BenchmarkPrefixHostmask(b *testing.B) {
+	for _, bb := range []struct {
+		name string
+		p    *ipaddr.Prefix
+	}{
+		{"IPv4", toPrefix("192.0.2.0/24")},
+		{"IPv6", toPrefix("2001:db8::/64")},
+	} {
+		b.Run(bb.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				hostmaskSink = bb.p.Hostmask()
+			}
+		})
+	}
+}
+
+func BenchmarkPrefixHostmaskBytes(b *testing.B) {
+	for _, bb := range []struct {
+		name string
+		p    *ipaddr.Prefix
+	}{
+		{"IPv4", toPrefix("192.0.2.0/24")},
+		{"IPv6", toPrefix("2001:db8::/64")},
+	} {
+		b.Run(bb.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				hostmaskSink = bb.p.HostmaskBytes()
+			}
+		})
+	}
+}
+
 func BenchmarkPrefixMarshalBinary(b *testing.B) {
 	for _, bb := range []struct {
 		name string
@@ -204,6 +289,22 @@ func BenchmarkPrefixOverlaps(b *testing.B) {
 	}
 }
 
+func BenchmarkPrefixString(b *testing.B) {
+	for _, bb := range []struct {
+		name string
+		p    *ipaddr.Prefix
+	}{
+		{"IPv4", toPrefix("192.0.2.0/31")},
+		{"IPv6", toPrefix("2001:db8:cafe:babe::/127")},
+	} {
+		b.Run(bb.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				_ = bb.p.String()
+			}
+		})
+	}
+}
+
 func BenchmarkPrefixSubnets(b *testing.B) {
 	for _, bb := range []struct {
 		name string
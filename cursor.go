@@ -7,6 +7,7 @@ package ipaddr
 import (
 	"errors"
 	"net"
+	"sort"
 )
 
 // A Cursor represents a movable indicator on single or multiple
@@ -15,10 +16,13 @@ type Cursor struct {
 	curr, start, end ipv6Int
 	pi               int
 	ps               []Prefix
+	contiguous       bool // walk through gaps between prefixes
+	gap              bool // current position is in the gap after ps[pi]
 }
 
 func (c *Cursor) set(pi int, ip net.IP) {
 	c.pi = pi
+	c.gap = false
 	c.curr = ipToIPv6Int(ip.To16())
 	c.start = ipToIPv6Int(c.ps[c.pi].IP.To16())
 	if ip.To4() != nil {
@@ -29,6 +33,12 @@ func (c *Cursor) set(pi int, ip net.IP) {
 	}
 }
 
+// ConsumedPrefixes returns the list of prefixes before the prefix of
+// the current position on c.
+func (c *Cursor) ConsumedPrefixes() []Prefix {
+	return c.ps[:c.pi]
+}
+
 // First returns the start position on c.
 func (c *Cursor) First() *Position {
 	return &Position{IP: c.ps[0].IP, Prefix: c.ps[0]}
@@ -47,6 +57,11 @@ func (c *Cursor) List() []Prefix {
 // Next turns to the next position on c.
 // It returns nil at the end on c.
 func (c *Cursor) Next() *Position {
+	if c.contiguous {
+		if c.nextGap() {
+			return c.Pos()
+		}
+	}
 	n := c.curr.cmp(&c.end)
 	if n == 0 {
 		if c.pi == len(c.ps)-1 {
@@ -67,14 +82,52 @@ func (c *Cursor) Next() *Position {
 	return c.Pos()
 }
 
+func (c *Cursor) nextGap() bool {
+	if c.gap {
+		c.curr.incr()
+		next := ipToIPv6Int(c.ps[c.pi+1].IP.To16())
+		if c.curr.cmp(&next) != 0 {
+			return true
+		}
+		c.set(c.pi+1, c.ps[c.pi+1].IP.To16())
+		return true
+	}
+	if c.curr.cmp(&c.end) != 0 || c.pi == len(c.ps)-1 {
+		return false
+	}
+	if (c.ps[c.pi].IP.To4() != nil) != (c.ps[c.pi+1].IP.To4() != nil) {
+		return false // no gap between address families
+	}
+	next := ipToIPv6Int(c.ps[c.pi+1].IP.To16())
+	curr := c.curr
+	curr.incr()
+	if curr.cmp(&next) >= 0 {
+		return false
+	}
+	c.curr = curr
+	c.gap = true
+	return true
+}
+
 // Pos returns the current position on c.
+// The prefix of the position is the zero value when c is created by
+// NewCursorContiguous and the current position is in a gap between
+// prefixes.
 func (c *Cursor) Pos() *Position {
+	if c.gap {
+		return &Position{IP: c.curr.ip()}
+	}
 	return &Position{IP: c.curr.ip(), Prefix: c.ps[c.pi]}
 }
 
 // Prev turns to the previous position on c.
 // It returns nil at the start on c.
 func (c *Cursor) Prev() *Position {
+	if c.contiguous {
+		if c.prevGap() {
+			return c.Pos()
+		}
+	}
 	n := c.curr.cmp(&c.start)
 	if n == 0 {
 		if c.pi == 0 {
@@ -96,6 +149,42 @@ func (c *Cursor) Prev() *Position {
 	return c.Pos()
 }
 
+func (c *Cursor) prevGap() bool {
+	if c.gap {
+		c.curr.decr()
+		if c.curr.cmp(&c.end) == 0 {
+			c.gap = false
+		}
+		return true
+	}
+	if c.curr.cmp(&c.start) != 0 || c.pi == 0 {
+		return false
+	}
+	if (c.ps[c.pi].IP.To4() != nil) != (c.ps[c.pi-1].IP.To4() != nil) {
+		return false // no gap between address families
+	}
+	prev := &c.ps[c.pi-1]
+	end := prev.lastIPv6Int()
+	if prev.IP.To4() != nil {
+		end = prev.lastIPv4MappedIPv6Int()
+	}
+	curr := c.curr
+	curr.decr()
+	if end.cmp(&curr) >= 0 {
+		return false
+	}
+	c.set(c.pi-1, prev.Last())
+	c.curr = curr
+	c.gap = true
+	return true
+}
+
+// RemainingPrefixes returns the list of prefixes from the prefix of
+// the current position on c.
+func (c *Cursor) RemainingPrefixes() []Prefix {
+	return c.ps[c.pi:]
+}
+
 // Reset resets all state and switches to ps.
 // It uses the existing prefixes when ps is nil.
 func (c *Cursor) Reset(ps []Prefix) {
@@ -125,6 +214,24 @@ func (c *Cursor) Set(pos *Position) error {
 	return nil
 }
 
+// SetPrefix sets the current position on c to the first address of
+// p.
+// It returns an error when p is not on c.
+func (c *Cursor) SetPrefix(p *Prefix) error {
+	if p == nil || p.IP.To16() == nil {
+		return errors.New("invalid prefix")
+	}
+	p = &Prefix{IPNet: net.IPNet{IP: p.IP.To16(), Mask: p.Mask}}
+	i := sort.Search(len(c.ps), func(i int) bool {
+		return compareAscending(&c.ps[i], p) >= 0
+	})
+	if i == len(c.ps) || !c.ps[i].Equal(p) {
+		return errors.New("prefix out of range")
+	}
+	c.set(i, c.ps[i].IP.To16())
+	return nil
+}
+
 // NewCursor returns a new cursor.
 func NewCursor(ps []Prefix) *Cursor {
 	ps = newSortedPrefixes(ps, sortAscending, false)
@@ -135,3 +242,52 @@ func NewCursor(ps []Prefix) *Cursor {
 	c.set(0, c.ps[0].IP.To16())
 	return c
 }
+
+// NewCursorContiguous returns a new cursor that walks through every
+// address from the start position to the end position, including the
+// addresses in gaps between prefixes of the same address family.
+func NewCursorContiguous(ps []Prefix) *Cursor {
+	c := NewCursor(ps)
+	if c != nil {
+		c.contiguous = true
+	}
+	return c
+}
+
+// NewCursorErr is like NewCursor but returns an error that describes
+// why no cursor can be built instead of nil.
+// It ignores prefixes that have no valid address or mask.
+func NewCursorErr(ps []Prefix) (*Cursor, error) {
+	if len(ps) == 0 {
+		return nil, errors.New("no prefixes")
+	}
+	var nps []Prefix
+	for i := range ps {
+		if ps[i].IP.To16() == nil {
+			continue
+		}
+		if _, bits := ps[i].Mask.Size(); bits == 0 {
+			continue
+		}
+		nps = append(nps, ps[i])
+	}
+	if len(nps) == 0 {
+		return nil, errors.New("all prefixes invalid")
+	}
+	return NewCursor(nps), nil
+}
+
+// NewCursorsByFamily returns a pair of new cursors, one on the IPv4
+// prefixes and the other on the IPv6 prefixes in ps.
+// It returns nil for an address family that has no prefix in ps.
+func NewCursorsByFamily(ps []Prefix) (v4, v6 *Cursor) {
+	var ps4, ps6 []Prefix
+	for i := range ps {
+		if ps[i].IP.To4() != nil {
+			ps4 = append(ps4, ps[i])
+		} else {
+			ps6 = append(ps6, ps[i])
+		}
+	}
+	return NewCursor(ps4), NewCursor(ps6)
+}
@@ -244,6 +244,121 @@ func TestCursorPrevNext(t *testing.T) {
 	}
 }
 
+func TestCursorContiguous(t *testing.T) {
+	for i, tt := range []struct {
+		ps   []ipaddr.Prefix
+		want []ipaddr.Position
+	}{
+		{
+			toPrefixes([]string{"192.0.2.0/31", "192.0.2.4/31"}),
+			[]ipaddr.Position{
+				*toPosition("192.0.2.0", "192.0.2.0/31"),
+				*toPosition("192.0.2.1", "192.0.2.0/31"),
+				{IP: net.ParseIP("192.0.2.2")},
+				{IP: net.ParseIP("192.0.2.3")},
+				*toPosition("192.0.2.4", "192.0.2.4/31"),
+				*toPosition("192.0.2.5", "192.0.2.4/31"),
+			},
+		},
+		{
+			toPrefixes([]string{"192.0.2.0/31", "192.0.2.2/32", "192.0.2.4/32"}),
+			[]ipaddr.Position{
+				*toPosition("192.0.2.0", "192.0.2.0/31"),
+				*toPosition("192.0.2.1", "192.0.2.0/31"),
+				*toPosition("192.0.2.2", "192.0.2.2/32"),
+				{IP: net.ParseIP("192.0.2.3")},
+				*toPosition("192.0.2.4", "192.0.2.4/32"),
+			},
+		},
+		{
+			toPrefixes([]string{"2001:db8::/127", "2001:db8::3/128"}),
+			[]ipaddr.Position{
+				*toPosition("2001:db8::", "2001:db8::/127"),
+				*toPosition("2001:db8::1", "2001:db8::/127"),
+				{IP: net.ParseIP("2001:db8::2")},
+				*toPosition("2001:db8::3", "2001:db8::3/128"),
+			},
+		},
+		{
+			toPrefixes([]string{"10.255.255.255/32", "2001:db8::/127"}),
+			[]ipaddr.Position{
+				*toPosition("10.255.255.255", "10.255.255.255/32"),
+				*toPosition("2001:db8::", "2001:db8::/127"),
+				*toPosition("2001:db8::1", "2001:db8::/127"),
+			},
+		},
+		{
+			toPrefixes([]string{"::1/128", "192.0.2.0/32", "192.0.2.2/32"}),
+			[]ipaddr.Position{
+				*toPosition("::1", "::1/128"),
+				*toPosition("192.0.2.0", "192.0.2.0/32"),
+				{IP: net.ParseIP("192.0.2.1")},
+				*toPosition("192.0.2.2", "192.0.2.2/32"),
+			},
+		},
+	} {
+		c := ipaddr.NewCursorContiguous(tt.ps)
+		var ps []ipaddr.Position
+		for pos := c.Pos(); pos != nil; pos = c.Next() {
+			ps = append(ps, *pos)
+		}
+		if !reflect.DeepEqual(ps, tt.want) {
+			t.Errorf("#%d: got %v; want %v", i, ps, tt.want)
+		}
+		ps = nil
+		for pos := c.Pos(); pos != nil; pos = c.Prev() {
+			ps = append([]ipaddr.Position{*pos}, ps...)
+		}
+		if !reflect.DeepEqual(ps, tt.want) {
+			t.Errorf("#%d: got %v; want %v", i, ps, tt.want)
+		}
+	}
+}
+
+func TestCursorRemainingConsumedPrefixes(t *testing.T) {
+	for i, tt := range []struct {
+		ps                  []ipaddr.Prefix
+		n                   int
+		remaining, consumed []ipaddr.Prefix
+	}{
+		{
+			toPrefixes([]string{"192.168.0.0/30", "192.168.1.0/30", "2001:db8::/126"}),
+			0,
+			toPrefixes([]string{"192.168.0.0/30", "192.168.1.0/30", "2001:db8::/126"}),
+			[]ipaddr.Prefix{},
+		},
+		{
+			toPrefixes([]string{"192.168.0.0/30", "192.168.1.0/30", "2001:db8::/126"}),
+			3,
+			toPrefixes([]string{"192.168.0.0/30", "192.168.1.0/30", "2001:db8::/126"}),
+			[]ipaddr.Prefix{},
+		},
+		{
+			toPrefixes([]string{"192.168.0.0/30", "192.168.1.0/30", "2001:db8::/126"}),
+			4,
+			toPrefixes([]string{"192.168.1.0/30", "2001:db8::/126"}),
+			toPrefixes([]string{"192.168.0.0/30"}),
+		},
+		{
+			toPrefixes([]string{"192.168.0.0/30", "192.168.1.0/30", "2001:db8::/126"}),
+			11,
+			toPrefixes([]string{"2001:db8::/126"}),
+			toPrefixes([]string{"192.168.0.0/30", "192.168.1.0/30"}),
+		},
+	} {
+		c := ipaddr.NewCursor(tt.ps)
+		for j := 0; j < tt.n; j++ {
+			c.Next()
+		}
+		if out := c.RemainingPrefixes(); !reflect.DeepEqual(out, tt.remaining) {
+			t.Errorf("#%d: got %v; want %v", i, out, tt.remaining)
+		}
+		if out := c.ConsumedPrefixes(); !reflect.DeepEqual(out, tt.consumed) {
+			t.Errorf("#%d: got %v; want %v", i, out, tt.consumed)
+		}
+	}
+}
+
 func TestCursorReset(t *testing.T) {
 	for i, tt := range []struct {
 		in  []ipaddr.Prefix
@@ -357,6 +472,70 @@ func TestCursorPos(t *testing.T) {
 	}
 }
 
+func TestCursorSetPrefix(t *testing.T) {
+	for i, tt := range []struct {
+		ps []ipaddr.Prefix
+		in *ipaddr.Prefix
+		error
+	}{
+		{
+			toPrefixes([]string{"192.168.2.0/24", "192.168.0.0/24", "192.168.1.0/24"}),
+			toPrefix("192.168.1.0/24"),
+			nil,
+		},
+		{
+			toPrefixes([]string{"192.168.0.0/24", "192.168.1.0/24", "192.168.2.0/24"}),
+			toPrefix("192.168.1.0/25"),
+			errors.New("should fail"),
+		},
+		{
+			toPrefixes([]string{"192.168.0.0/24", "192.168.1.0/24", "192.168.2.0/24"}),
+			toPrefix("192.168.3.0/24"),
+			errors.New("should fail"),
+		},
+
+		{
+			toPrefixes([]string{"2001:db8::/64", "2001:db8:1::/64", "2001:db8:2::/64"}),
+			toPrefix("2001:db8:2::/64"),
+			nil,
+		},
+		{
+			toPrefixes([]string{"192.168.0.0/24", "2001:db8::/64"}),
+			toPrefix("2001:db8::/64"),
+			nil,
+		},
+		{
+			toPrefixes([]string{"192.168.0.0/24", "2001:db8::/64"}),
+			toPrefix("::/0"),
+			errors.New("should fail"),
+		},
+	} {
+		c := ipaddr.NewCursor(tt.ps)
+		err := c.SetPrefix(tt.in)
+		if (err != nil) != (tt.error != nil) {
+			t.Errorf("#%d: got %v; want %v", i, err, tt.error)
+		}
+		if err != nil {
+			continue
+		}
+		if pos := c.Pos(); !pos.IP.Equal(tt.in.IP) || !pos.Prefix.Equal(tt.in) {
+			t.Errorf("#%d: got %v; want %v", i, pos, tt.in)
+		}
+	}
+
+	c := ipaddr.NewCursor(toPrefixes([]string{"192.168.0.0/24", "192.168.1.0/24", "192.168.2.0/24"}))
+	p := &ipaddr.Prefix{IPNet: net.IPNet{IP: net.IPv4(192, 168, 1, 0).To4(), Mask: net.CIDRMask(24, ipaddr.IPv4PrefixLen)}}
+	if err := c.SetPrefix(p); err != nil {
+		t.Fatal(err)
+	}
+	if pos, want := c.Pos(), toPrefix("192.168.1.0/24"); !pos.IP.Equal(want.IP) || !pos.Prefix.Equal(want) {
+		t.Errorf("got %v; want %v", pos, want)
+	}
+	if err := c.SetPrefix(&ipaddr.Prefix{}); err == nil {
+		t.Error("got nil; want an error")
+	}
+}
+
 func TestNewCursor(t *testing.T) {
 	for i, tt := range []struct {
 		in []string
@@ -396,3 +575,57 @@ func TestNewCursor(t *testing.T) {
 
 	ipaddr.NewCursor(nil)
 }
+
+func TestNewCursorErr(t *testing.T) {
+	for i, tt := range []struct {
+		in  []ipaddr.Prefix
+		err string
+	}{
+		{nil, "no prefixes"},
+		{[]ipaddr.Prefix{}, "no prefixes"},
+		{[]ipaddr.Prefix{{}, {IPNet: net.IPNet{IP: net.ParseIP("192.0.2.0")}}}, "all prefixes invalid"},
+		{[]ipaddr.Prefix{{}, *toPrefix("192.0.2.0/24")}, ""},
+	} {
+		c, err := ipaddr.NewCursorErr(tt.in)
+		if tt.err != "" {
+			if err == nil || err.Error() != tt.err {
+				t.Errorf("#%d: got %v; want %v", i, err, tt.err)
+			}
+			if c != nil {
+				t.Errorf("#%d: got %v; want nil", i, c)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("#%d: %v", i, err)
+			continue
+		}
+		if pos := c.First(); !pos.Prefix.Equal(toPrefix("192.0.2.0/24")) {
+			t.Errorf("#%d: got %v; want 192.0.2.0/24", i, pos.Prefix)
+		}
+	}
+}
+
+func TestNewCursorsByFamily(t *testing.T) {
+	for i, tt := range []struct {
+		in     []string
+		v4, v6 []string
+	}{
+		{
+			[]string{"2001:db8::/64", "192.0.2.0/24", "2001:db8:1::/48", "198.51.100.0/24"},
+			[]string{"192.0.2.0/24", "198.51.100.0/24"},
+			[]string{"2001:db8::/64", "2001:db8:1::/48"},
+		},
+		{[]string{"192.0.2.0/24"}, []string{"192.0.2.0/24"}, nil},
+		{[]string{"2001:db8::/64"}, nil, []string{"2001:db8::/64"}},
+		{nil, nil, nil},
+	} {
+		v4, v6 := ipaddr.NewCursorsByFamily(toPrefixes(tt.in))
+		if tt.v4 == nil && v4 != nil || tt.v4 != nil && !reflect.DeepEqual(v4.List(), toPrefixes(tt.v4)) {
+			t.Errorf("#%d: got %v; want %v", i, v4, tt.v4)
+		}
+		if tt.v6 == nil && v6 != nil || tt.v6 != nil && !reflect.DeepEqual(v6.List(), toPrefixes(tt.v6)) {
+			t.Errorf("#%d: got %v; want %v", i, v6, tt.v6)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+// Copyright 2013 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.
+
+package ipaddr
+
+import (
+	"fmt"
+	"math/big"
+)
+
+// Debug enables the validation of invariants in Aggregate and
+// Supernet.
+// When an invariant is violated, they panic with a message that
+// includes the input prefixes.
+var Debug = false
+
+func clonePrefixes(ps []Prefix) []Prefix {
+	nps := make([]Prefix, len(ps))
+	for i := range ps {
+		nps[i] = *clonePrefix(&ps[i])
+	}
+	return nps
+}
+
+// checkAggregate validates that out, the result of Aggregate for in,
+// is sorted in ascending order and covers exactly the same addresses
+// as in.
+func checkAggregate(in, out []Prefix) {
+	for i := 1; i < len(out); i++ {
+		if compareAscending(&out[i-1], &out[i]) >= 0 {
+			panic(fmt.Sprintf("ipaddr: Aggregate(%v) = %v: unsorted result", in, out))
+		}
+	}
+	for i := range in {
+		covered := false
+		for j := range out {
+			if out[j].Equal(&in[i]) || out[j].Contains(&in[i]) {
+				covered = true
+				break
+			}
+		}
+		if !covered {
+			panic(fmt.Sprintf("ipaddr: Aggregate(%v) = %v: %v got lost", in, out, in[i]))
+		}
+	}
+	rs := make([]Range, len(in))
+	for i := range in {
+		rs[i] = Range{First: in[i].IP, Last: in[i].Last()}
+	}
+	_, want := SummarizeAll(rs)
+	got := new(big.Int)
+	for i := range out {
+		got.Add(got, out[i].NumNodes())
+	}
+	if got.Cmp(want) != 0 {
+		panic(fmt.Sprintf("ipaddr: Aggregate(%v) = %v: %v addresses covered; want %v", in, out, got, want))
+	}
+}
+
+// checkSupernet validates that super, the result of Supernet for in,
+// contains all the prefixes in in.
+func checkSupernet(in []Prefix, super *Prefix) {
+	for i := range in {
+		if !super.Equal(&in[i]) && !super.Contains(&in[i]) {
+			panic(fmt.Sprintf("ipaddr: Supernet(%v) = %v: %v not contained", in, super, in[i]))
+		}
+	}
+}
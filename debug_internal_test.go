@@ -0,0 +1,77 @@
+// Copyright 2013 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.
+
+package ipaddr
+
+import (
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestCheckAggregate(t *testing.T) {
+	for i, tt := range []struct {
+		in, out []string
+		err     string
+	}{
+		{[]string{"192.0.2.0/25", "192.0.2.128/25"}, []string{"192.0.2.0/24"}, ""},
+		{[]string{"192.0.2.0/24", "198.51.100.0/24"}, []string{"198.51.100.0/24", "192.0.2.0/24"}, "unsorted result"},
+		{[]string{"192.0.2.0/24", "198.51.100.0/24"}, []string{"192.0.2.0/24"}, "198.51.100.0/24 got lost"},
+		{[]string{"10.0.0.0/24", "10.0.2.0/24"}, []string{"10.0.0.0/22"}, "addresses covered"},
+		{[]string{"2001:db8::/33", "2001:db8:8000::/34"}, []string{"2001:db8::/32"}, "addresses covered"},
+	} {
+		err := func() (err interface{}) {
+			defer func() { err = recover() }()
+			checkAggregate(toPrefixes(tt.in), toPrefixes(tt.out))
+			return nil
+		}()
+		if tt.err == "" && err != nil {
+			t.Errorf("#%d: %v", i, err)
+		}
+		if tt.err != "" && (err == nil || !strings.Contains(err.(string), tt.err)) {
+			t.Errorf("#%d: got %v; want a panic including %v", i, err, tt.err)
+		}
+	}
+}
+
+func TestCheckSupernet(t *testing.T) {
+	for i, tt := range []struct {
+		in    []string
+		super string
+		err   string
+	}{
+		{[]string{"192.0.2.0/25", "192.0.2.128/25"}, "192.0.2.0/24", ""},
+		{[]string{"192.0.2.0/24"}, "192.0.2.0/24", ""},
+		{[]string{"192.0.2.0/24", "198.51.100.0/24"}, "192.0.2.0/24", "198.51.100.0/24 not contained"},
+		{[]string{"2001:db8::/32", "2001:dba::/32"}, "2001:db8::/31", "2001:dba::/32 not contained"},
+	} {
+		err := func() (err interface{}) {
+			defer func() { err = recover() }()
+			checkSupernet(toPrefixes(tt.in), toPrefix(tt.super))
+			return nil
+		}()
+		if tt.err == "" && err != nil {
+			t.Errorf("#%d: %v", i, err)
+		}
+		if tt.err != "" && (err == nil || !strings.Contains(err.(string), tt.err)) {
+			t.Errorf("#%d: got %v; want a panic including %v", i, err, tt.err)
+		}
+	}
+}
+
+func toPrefix(s string) *Prefix {
+	_, n, err := net.ParseCIDR(s)
+	if err != nil {
+		return nil
+	}
+	return NewPrefix(n)
+}
+
+func toPrefixes(ss []string) []Prefix {
+	var ps []Prefix
+	for _, s := range ss {
+		ps = append(ps, *toPrefix(s))
+	}
+	return ps
+}
@@ -0,0 +1,41 @@
+// Copyright 2013 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.
+
+package ipaddr_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mikioh/ipaddr"
+)
+
+func TestDebug(t *testing.T) {
+	ipaddr.Debug = true
+	defer func() { ipaddr.Debug = false }()
+
+	for i, tt := range []struct {
+		in  []string
+		err string
+	}{
+		{[]string{"192.0.2.0/25", "192.0.2.128/25", "198.51.100.0/24"}, ""},
+		{[]string{"2001:db8::/33", "2001:db8:8000::/33"}, ""},
+		{[]string{"10.0.0.0/22", "10.0.8.0/22", "10.0.10.0/23", "10.0.8.0/23", "10.0.0.0/21", "10.0.9.128/25"}, ""},
+		{[]string{"0.0.0.0/1", "128.0.0.0/1"}, ""},
+	} {
+		err := func() (err interface{}) {
+			defer func() { err = recover() }()
+			ps := toPrefixes(tt.in)
+			ipaddr.Aggregate(ps)
+			ipaddr.Supernet(ps)
+			return nil
+		}()
+		if tt.err == "" && err != nil {
+			t.Errorf("#%d: %v", i, err)
+		}
+		if tt.err != "" && (err == nil || !strings.Contains(err.(string), tt.err)) {
+			t.Errorf("#%d: got %v; want a panic including %v", i, err, tt.err)
+		}
+	}
+}
@@ -27,39 +27,39 @@ func ExampleCursor_traversal() {
 	}
 	fmt.Println(c.Pos(), c.First(), c.Last(), c.List())
 	// Output:
-	// &{192.0.2.128 192.0.2.128/30} &{192.0.2.128 192.0.2.128/30} &{2001:db8::3 2001:db8::/126} [192.0.2.128/30 198.51.100.0/29 2001:db8::/126]
-	// &{192.0.2.129 192.0.2.128/30}
-	// &{192.0.2.130 192.0.2.128/30}
-	// &{192.0.2.131 192.0.2.128/30}
-	// &{198.51.100.0 198.51.100.0/29}
-	// &{198.51.100.1 198.51.100.0/29}
-	// &{198.51.100.2 198.51.100.0/29}
-	// &{198.51.100.3 198.51.100.0/29}
-	// &{198.51.100.4 198.51.100.0/29}
-	// &{198.51.100.5 198.51.100.0/29}
-	// &{198.51.100.6 198.51.100.0/29}
-	// &{198.51.100.7 198.51.100.0/29}
-	// &{2001:db8:: 2001:db8::/126}
-	// &{2001:db8::1 2001:db8::/126}
-	// &{2001:db8::2 2001:db8::/126}
-	// &{2001:db8::3 2001:db8::/126}
-	// &{2001:db8::3 2001:db8::/126} &{192.0.2.128 192.0.2.128/30} &{2001:db8::3 2001:db8::/126} [192.0.2.128/30 198.51.100.0/29 2001:db8::/126]
-	// &{2001:db8::2 2001:db8::/126}
-	// &{2001:db8::1 2001:db8::/126}
-	// &{2001:db8:: 2001:db8::/126}
-	// &{198.51.100.7 198.51.100.0/29}
-	// &{198.51.100.6 198.51.100.0/29}
-	// &{198.51.100.5 198.51.100.0/29}
-	// &{198.51.100.4 198.51.100.0/29}
-	// &{198.51.100.3 198.51.100.0/29}
-	// &{198.51.100.2 198.51.100.0/29}
-	// &{198.51.100.1 198.51.100.0/29}
-	// &{198.51.100.0 198.51.100.0/29}
-	// &{192.0.2.131 192.0.2.128/30}
-	// &{192.0.2.130 192.0.2.128/30}
-	// &{192.0.2.129 192.0.2.128/30}
-	// &{192.0.2.128 192.0.2.128/30}
-	// &{192.0.2.128 192.0.2.128/30} &{192.0.2.128 192.0.2.128/30} &{2001:db8::3 2001:db8::/126} [192.0.2.128/30 198.51.100.0/29 2001:db8::/126]
+	// 192.0.2.128/30 192.0.2.128/30 2001:db8::3/126 [192.0.2.128/30 198.51.100.0/29 2001:db8::/126]
+	// 192.0.2.129/30
+	// 192.0.2.130/30
+	// 192.0.2.131/30
+	// 198.51.100.0/29
+	// 198.51.100.1/29
+	// 198.51.100.2/29
+	// 198.51.100.3/29
+	// 198.51.100.4/29
+	// 198.51.100.5/29
+	// 198.51.100.6/29
+	// 198.51.100.7/29
+	// 2001:db8::/126
+	// 2001:db8::1/126
+	// 2001:db8::2/126
+	// 2001:db8::3/126
+	// 2001:db8::3/126 192.0.2.128/30 2001:db8::3/126 [192.0.2.128/30 198.51.100.0/29 2001:db8::/126]
+	// 2001:db8::2/126
+	// 2001:db8::1/126
+	// 2001:db8::/126
+	// 198.51.100.7/29
+	// 198.51.100.6/29
+	// 198.51.100.5/29
+	// 198.51.100.4/29
+	// 198.51.100.3/29
+	// 198.51.100.2/29
+	// 198.51.100.1/29
+	// 198.51.100.0/29
+	// 192.0.2.131/30
+	// 192.0.2.130/30
+	// 192.0.2.129/30
+	// 192.0.2.128/30
+	// 192.0.2.128/30 192.0.2.128/30 2001:db8::3/126 [192.0.2.128/30 198.51.100.0/29 2001:db8::/126]
 }
 
 func ExamplePrefix_subnettingAndSupernetting() {
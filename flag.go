@@ -0,0 +1,39 @@
+// Copyright 2015 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.
+
+package ipaddr
+
+import "flag"
+
+var _ flag.Value = &PrefixList{}
+
+// A PrefixList represents a list of IP address prefixes.
+// It implements flag.Value and accumulates prefixes on each Set.
+type PrefixList []Prefix
+
+// Set parses s as ParsePrefixes does and appends the parsed prefixes
+// to ps.
+func (ps *PrefixList) Set(s string) error {
+	nps, err := ParsePrefixes(s)
+	if err != nil {
+		return err
+	}
+	*ps = append(*ps, nps...)
+	return nil
+}
+
+// String returns a comma-separated text form of ps.
+func (ps *PrefixList) String() string {
+	if ps == nil {
+		return ""
+	}
+	var b []byte
+	for i := range *ps {
+		if i > 0 {
+			b = append(b, ',')
+		}
+		b = (*ps)[i].AppendString(b)
+	}
+	return string(b)
+}
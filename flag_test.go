@@ -0,0 +1,66 @@
+// Copyright 2015 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.
+
+package ipaddr_test
+
+import (
+	"flag"
+	"io/ioutil"
+	"reflect"
+	"testing"
+
+	"github.com/mikioh/ipaddr"
+)
+
+func TestPrefixList(t *testing.T) {
+	for i, tt := range []struct {
+		args []string
+		ps   []ipaddr.Prefix
+		s    string
+		ok   bool
+	}{
+		{
+			[]string{"-net", "10.0.0.0/8", "-net", "192.168.0.0/16"},
+			toPrefixes([]string{"10.0.0.0/8", "192.168.0.0/16"}),
+			"10.0.0.0/8,192.168.0.0/16",
+			true,
+		},
+		{
+			[]string{"-net", "2001:db8::/32,192.0.2.1", "-net=198.51.100.0/24"},
+			toPrefixes([]string{"2001:db8::/32", "192.0.2.1/32", "198.51.100.0/24"}),
+			"2001:db8::/32,192.0.2.1/32,198.51.100.0/24",
+			true,
+		},
+		{
+			nil,
+			nil,
+			"",
+			true,
+		},
+		{
+			[]string{"-net", "10.0.0.0/8", "-net", "10.0.0.0/33"},
+			nil,
+			"",
+			false,
+		},
+	} {
+		var ps ipaddr.PrefixList
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		fs.SetOutput(ioutil.Discard)
+		fs.Var(&ps, "net", "network")
+		err := fs.Parse(tt.args)
+		if err != nil && tt.ok || err == nil && !tt.ok {
+			t.Errorf("#%d: got %v; want ok=%v", i, err, tt.ok)
+		}
+		if err != nil {
+			continue
+		}
+		if !reflect.DeepEqual([]ipaddr.Prefix(ps), tt.ps) {
+			t.Errorf("#%d: got %v; want %v", i, ps, tt.ps)
+		}
+		if s := ps.String(); s != tt.s {
+			t.Errorf("#%d: got %v; want %v", i, s, tt.s)
+		}
+	}
+}
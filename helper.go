@@ -4,6 +4,43 @@
 
 package ipaddr
 
+import (
+	"net"
+	"strconv"
+)
+
+var (
+	ipv4Hostmasks = newHostmasks(IPv4PrefixLen)
+	ipv6Hostmasks = newHostmasks(IPv6PrefixLen)
+)
+
+func newHostmasks(z int) []net.IPMask {
+	ms := make([]net.IPMask, z+1)
+	for l := range ms {
+		ms[l] = invert(net.CIDRMask(l, z))
+	}
+	return ms
+}
+
+func allZeros(b []byte) bool {
+	for _, v := range b {
+		if v != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// bitField returns the nbits bits of b at the bit position pos,
+// counted from the most significant bit of b.
+func bitField(b []byte, pos, nbits int) uint32 {
+	var v uint32
+	for i := pos; i < pos+nbits; i++ {
+		v = v<<1 | uint32(b[i/8]>>uint(7-i%8))&1
+	}
+	return v
+}
+
 func mask32(nbits int) uint32 {
 	return -uint32(1 << uint(32-nbits))
 }
@@ -11,3 +48,45 @@ func mask32(nbits int) uint32 {
 func mask64(nbits int) uint64 {
 	return -uint64(1 << uint(64-nbits))
 }
+
+// appendIP appends a text form of ip, the same as ip.String returns,
+// to b and returns the extended buffer.
+// The length of ip must be net.IPv4len or net.IPv6len.
+func appendIP(b []byte, ip net.IP) []byte {
+	if ip4 := ip.To4(); ip4 != nil {
+		for i := range ip4 {
+			if i > 0 {
+				b = append(b, '.')
+			}
+			b = strconv.AppendUint(b, uint64(ip4[i]), 10)
+		}
+		return b
+	}
+	e0, e1 := -1, -1 // the longest run of zero fields
+	for i := 0; i < net.IPv6len; i += 2 {
+		j := i
+		for j < net.IPv6len && ip[j] == 0 && ip[j+1] == 0 {
+			j += 2
+		}
+		if j > i && j-i > e1-e0 {
+			e0, e1 = i, j
+			i = j
+		}
+	}
+	if e1-e0 <= 2 {
+		e0, e1 = -1, -1
+	}
+	for i := 0; i < net.IPv6len; i += 2 {
+		if i == e0 {
+			b = append(b, ':', ':')
+			i = e1
+			if i >= net.IPv6len {
+				break
+			}
+		} else if i > 0 {
+			b = append(b, ':')
+		}
+		b = strconv.AppendUint(b, uint64(ip[i])<<8|uint64(ip[i+1]), 16)
+	}
+	return b
+}
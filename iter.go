@@ -0,0 +1,24 @@
+// Copyright 2013 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.
+
+//go:build go1.23
+
+package ipaddr
+
+import "iter"
+
+// AllSubnets returns an iterator over the prefixes that are split
+// from p by n, as Subnets does, in ascending order.
+// The iterator yields no prefix when n is negative or the length of p
+// plus n exceeds the maximum prefix length of the address family.
+func (p *Prefix) AllSubnets(n int) iter.Seq[Prefix] {
+	return func(yield func(Prefix) bool) {
+		if n < 0 {
+			return
+		}
+		p.ContainedBlocksFunc(p.Len()+n, func(sub *Prefix) bool {
+			return yield(*sub)
+		})
+	}
+}
@@ -0,0 +1,57 @@
+// Copyright 2013 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.
+
+//go:build go1.23
+
+package ipaddr_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/mikioh/ipaddr"
+)
+
+func TestPrefixAllSubnets(t *testing.T) {
+	for i, tt := range []struct {
+		in string
+		n  int
+		ok bool
+	}{
+		{"192.168.0.0/24", 0, true},
+		{"192.168.0.0/24", 2, true},
+		{"192.168.0.0/24", 8, true},
+		{"192.168.0.0/24", 9, false},
+		{"192.168.0.0/24", -1, false},
+		{"0.0.0.0/0", 4, true},
+
+		{"2001:db8::/64", 4, true},
+		{"2001:db8::/120", 8, true},
+		{"2001:db8::/120", 9, false},
+	} {
+		p := toPrefix(tt.in)
+		var ps []ipaddr.Prefix
+		for sub := range p.AllSubnets(tt.n) {
+			ps = append(ps, sub)
+		}
+		var want []ipaddr.Prefix
+		if tt.ok {
+			want = p.Subnets(tt.n)
+		}
+		if !reflect.DeepEqual(ps, want) {
+			t.Errorf("#%d: got %v; want %v", i, ps, want)
+		}
+	}
+
+	n := 0
+	for range toPrefix("::/0").AllSubnets(64) {
+		n++
+		if n == 3 {
+			break
+		}
+	}
+	if n != 3 {
+		t.Errorf("got %v; want 3", n)
+	}
+}
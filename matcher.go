@@ -0,0 +1,66 @@
+// Copyright 2013 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.
+
+package ipaddr
+
+import (
+	"net"
+	"sort"
+)
+
+// A Matcher represents a fixed set of prefixes prepared for finding
+// the prefixes that contain an IP address.
+type Matcher struct {
+	lens4, lens6 []int                // distinct prefix lengths
+	idxs         map[matcherKey][]int // indices of prefixes
+}
+
+type matcherKey struct {
+	ipv4 bool
+	l    int
+	ip   [net.IPv6len]byte // address masked to l
+}
+
+func newMatcherKey(ip net.IP, l, z int) matcherKey {
+	k := matcherKey{ipv4: z == IPv4PrefixLen, l: l}
+	copy(k.ip[:], ipToPrefix(ip, l, z).IP)
+	return k
+}
+
+// Match returns the indices of all the prefixes that contain ip, in
+// ascending order.
+// The indices are in the list of prefixes given to NewMatcher.
+// It returns nil when no prefix contains ip.
+func (m *Matcher) Match(ip net.IP) []int {
+	if ip.To16() == nil {
+		return nil
+	}
+	lens, z := m.lens6, IPv6PrefixLen
+	if ip.To4() != nil {
+		lens, z = m.lens4, IPv4PrefixLen
+	}
+	var idxs []int
+	for _, l := range lens {
+		idxs = append(idxs, m.idxs[newMatcherKey(ip, l, z)]...)
+	}
+	sort.Ints(idxs)
+	return idxs
+}
+
+// NewMatcher returns a new matcher for ps.
+// It keeps the masked address of each prefix in a hash map per prefix
+// length, so that Match looks up only the prefix lengths in ps.
+func NewMatcher(ps []Prefix) *Matcher {
+	m := &Matcher{idxs: make(map[matcherKey][]int)}
+	m.lens4, m.lens6 = PresentLengths(ps)
+	for i := range ps {
+		z := IPv6PrefixLen
+		if ps[i].IP.To4() != nil {
+			z = IPv4PrefixLen
+		}
+		k := newMatcherKey(ps[i].IP, ps[i].Len(), z)
+		m.idxs[k] = append(m.idxs[k], i)
+	}
+	return m
+}
@@ -0,0 +1,47 @@
+// Copyright 2013 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.
+
+package ipaddr_test
+
+import (
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/mikioh/ipaddr"
+)
+
+func TestMatcher(t *testing.T) {
+	m := ipaddr.NewMatcher(toPrefixes([]string{
+		"10.0.0.0/8", "10.1.0.0/16", "192.0.2.0/24", "0.0.0.0/0", "10.1.2.0/24",
+		"2001:db8::/32", "::/0", "2001:db8:1::/48", "10.1.0.0/16",
+	}))
+	for i, tt := range []struct {
+		in   net.IP
+		want []int
+	}{
+		{net.ParseIP("10.1.2.3"), []int{0, 1, 3, 4, 8}},
+		{net.ParseIP("10.2.0.1"), []int{0, 3}},
+		{net.ParseIP("192.0.2.255"), []int{2, 3}},
+		{net.ParseIP("198.51.100.1"), []int{3}},
+
+		{net.ParseIP("2001:db8:1::1"), []int{5, 6, 7}},
+		{net.ParseIP("2001:db9::1"), []int{6}},
+		{nil, nil},
+	} {
+		if idxs := m.Match(tt.in); !reflect.DeepEqual(idxs, tt.want) {
+			t.Errorf("#%d: got %v; want %v", i, idxs, tt.want)
+		}
+	}
+
+	m = ipaddr.NewMatcher(toPrefixes([]string{"10.0.0.0/8", "2001:db8::/32"}))
+	for i, ip := range []net.IP{net.ParseIP("192.0.2.1"), net.ParseIP("::ffff:192.0.2.1"), net.ParseIP("2001:db9::1")} {
+		if idxs := m.Match(ip); idxs != nil {
+			t.Errorf("#%d: got %v; want nil", i, idxs)
+		}
+	}
+	if idxs := ipaddr.NewMatcher(nil).Match(net.ParseIP("10.0.0.1")); idxs != nil {
+		t.Errorf("got %v; want nil", idxs)
+	}
+}
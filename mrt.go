@@ -0,0 +1,64 @@
+// Copyright 2015 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.
+
+package ipaddr
+
+import (
+	"errors"
+	"io"
+	"net"
+)
+
+// A MRTPrefixDecoder reads and decodes the prefix fields of MRT
+// TABLE_DUMP_V2 RIB entries as described in RFC 6396.
+//
+// The prefix field consists of a prefix length in bits followed by
+// the minimum number of octets of the address, the same form as
+// BGP NLRI. The caller is responsible for stripping the surrounding
+// MRT framing.
+type MRTPrefixDecoder struct {
+	r   io.Reader
+	z   int
+	buf [1 + net.IPv6len]byte
+}
+
+// Decode reads the next prefix field from the input and returns the
+// decoded prefix.
+// It returns io.EOF when no more prefix field is available.
+func (d *MRTPrefixDecoder) Decode() (*Prefix, error) {
+	if _, err := io.ReadFull(d.r, d.buf[:1]); err != nil {
+		return nil, err
+	}
+	l := int(d.buf[0])
+	if l > d.z {
+		return nil, errors.New("invalid prefix length")
+	}
+	n := (l + 8 - 1) / 8
+	if _, err := io.ReadFull(d.r, d.buf[1:1+n]); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	ip := make(net.IP, d.z/8)
+	copy(ip, d.buf[1:1+n])
+	return ipToPrefix(ip, l, d.z), nil
+}
+
+// NewMRTPrefixDecoder returns a new decoder that reads from r.
+// The address family afi must be 1 for the prefix fields of
+// RIB_IPV4_UNICAST and RIB_IPV4_MULTICAST entries, or 2 for
+// RIB_IPV6_UNICAST and RIB_IPV6_MULTICAST entries.
+func NewMRTPrefixDecoder(r io.Reader, afi int) (*MRTPrefixDecoder, error) {
+	d := &MRTPrefixDecoder{r: r}
+	switch afi {
+	case 1:
+		d.z = IPv4PrefixLen
+	case 2:
+		d.z = IPv6PrefixLen
+	default:
+		return nil, errors.New("unknown address family")
+	}
+	return d, nil
+}
@@ -0,0 +1,81 @@
+// Copyright 2015 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.
+
+package ipaddr_test
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/mikioh/ipaddr"
+)
+
+func TestMRTPrefixDecoder(t *testing.T) {
+	for i, tt := range []struct {
+		afi  int
+		in   []byte
+		want []ipaddr.Prefix
+		err  error
+	}{
+		{
+			1,
+			[]byte{
+				0x18, 0xc0, 0x00, 0x02,
+				0x16, 0xc6, 0x33, 0x64,
+				0x20, 0xcb, 0x00, 0x71, 0x01,
+				0x00,
+				0x08, 0x0a,
+			},
+			toPrefixes([]string{"192.0.2.0/24", "198.51.100.0/22", "203.0.113.1/32", "0.0.0.0/0", "10.0.0.0/8"}),
+			io.EOF,
+		},
+		{
+			2,
+			[]byte{
+				0x20, 0x20, 0x01, 0x0d, 0xb8,
+				0x30, 0x20, 0x01, 0x0d, 0xb8, 0xca, 0xfe,
+				0x42, 0x20, 0x01, 0x0d, 0xb8, 0x00, 0x00, 0xca, 0xfe, 0x80,
+			},
+			toPrefixes([]string{"2001:db8::/32", "2001:db8:cafe::/48", "2001:db8:0:cafe:8000::/66"}),
+			io.EOF,
+		},
+		{
+			1,
+			[]byte{0x18, 0xc0, 0x00, 0x02, 0x18, 0xc6},
+			toPrefixes([]string{"192.0.2.0/24"}),
+			io.ErrUnexpectedEOF,
+		},
+		{
+			1,
+			[]byte{0x21, 0xc0, 0x00, 0x02, 0x00, 0x00},
+			nil,
+			nil,
+		},
+	} {
+		d, err := ipaddr.NewMRTPrefixDecoder(bytes.NewReader(tt.in), tt.afi)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var ps []ipaddr.Prefix
+		for {
+			p, err := d.Decode()
+			if err != nil {
+				if tt.err != nil && err != tt.err {
+					t.Errorf("#%d: got %v; want %v", i, err, tt.err)
+				}
+				break
+			}
+			ps = append(ps, *p)
+		}
+		if !reflect.DeepEqual(ps, tt.want) {
+			t.Errorf("#%d: got %v; want %v", i, ps, tt.want)
+		}
+	}
+
+	if _, err := ipaddr.NewMRTPrefixDecoder(bytes.NewReader(nil), 0); err == nil {
+		t.Error("should fail")
+	}
+}
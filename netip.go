@@ -0,0 +1,49 @@
+// Copyright 2013 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.
+
+//go:build go1.18
+
+package ipaddr
+
+import (
+	"errors"
+	"net"
+	"net/netip"
+)
+
+// NewPrefixFromNetip returns a new prefix from p.
+// It clears the address bits of p that exceed the length of p, and
+// keeps an IPv4-mapped IPv6 address prefix as an IPv6 prefix.
+// It returns an error when p is not valid.
+func NewPrefixFromNetip(p netip.Prefix) (*Prefix, error) {
+	if !p.IsValid() {
+		return nil, errors.New("invalid prefix")
+	}
+	p = p.Masked()
+	z := IPv6PrefixLen
+	if p.Addr().Is4() {
+		z = IPv4PrefixLen
+	}
+	a := p.Addr().As16()
+	ip := make(net.IP, net.IPv6len)
+	copy(ip, a[:])
+	return &Prefix{IPNet: net.IPNet{IP: ip, Mask: net.CIDRMask(p.Bits(), z)}}, nil
+}
+
+// ToNetip returns p as a netip.Prefix.
+// It returns false when p is not valid.
+func ToNetip(p *Prefix) (netip.Prefix, bool) {
+	l, z := p.Mask.Size()
+	switch {
+	case z == IPv4PrefixLen && p.IP.To4() != nil:
+		var a [net.IPv4len]byte
+		copy(a[:], p.IP.To4())
+		return netip.PrefixFrom(netip.AddrFrom4(a), l), true
+	case z == IPv6PrefixLen && len(p.IP) == net.IPv6len:
+		var a [net.IPv6len]byte
+		copy(a[:], p.IP)
+		return netip.PrefixFrom(netip.AddrFrom16(a), l), true
+	}
+	return netip.Prefix{}, false
+}
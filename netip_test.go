@@ -0,0 +1,58 @@
+// Copyright 2013 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.
+
+//go:build go1.18
+
+package ipaddr_test
+
+import (
+	"net"
+	"net/netip"
+	"reflect"
+	"testing"
+
+	"github.com/mikioh/ipaddr"
+)
+
+func TestNetip(t *testing.T) {
+	for i, tt := range []struct {
+		in   string
+		want string
+	}{
+		{"192.0.2.0/24", "192.0.2.0/24"},
+		{"192.0.2.1/24", "192.0.2.0/24"},
+		{"0.0.0.0/0", "0.0.0.0/0"},
+		{"255.255.255.255/32", "255.255.255.255/32"},
+
+		{"2001:db8::/32", "2001:db8::/32"},
+		{"2001:db8::1/128", "2001:db8::1/128"},
+		{"::/0", "::/0"},
+		{"::ffff:192.0.2.0/120", "::ffff:192.0.2.0/120"},
+	} {
+		in := netip.MustParsePrefix(tt.in)
+		p, err := ipaddr.NewPrefixFromNetip(in)
+		if err != nil {
+			t.Errorf("#%d: %v", i, err)
+			continue
+		}
+		_, n, err := net.ParseCIDR(tt.want)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := ipaddr.NewPrefix(n); !reflect.DeepEqual(p, want) {
+			t.Errorf("#%d: got %v; want %v", i, p, want)
+		}
+		out, ok := ipaddr.ToNetip(p)
+		if !ok || out != in.Masked() {
+			t.Errorf("#%d: got %v, %v; want %v", i, out, ok, in.Masked())
+		}
+	}
+
+	if _, err := ipaddr.NewPrefixFromNetip(netip.Prefix{}); err == nil {
+		t.Error("got nil; want an error")
+	}
+	if _, ok := ipaddr.ToNetip(&ipaddr.Prefix{}); ok {
+		t.Error("got true; want false")
+	}
+}
@@ -6,7 +6,9 @@ package ipaddr
 
 import (
 	"net"
+	"strconv"
 	"strings"
+	"unicode"
 )
 
 // Parse parses s as a single or combination of multiple IP addresses
@@ -33,6 +35,91 @@ func Parse(s string) (*Cursor, error) {
 	return NewCursor(ps), nil
 }
 
+// ParsePrefixes parses s as a list of IP addresses and IP address
+// prefixes separated by commas and/or white space, and returns the
+// list of prefixes in the order of appearance.
+//
+// Examples:
+//
+//	ParsePrefixes("192.0.2.1")
+//	ParsePrefixes("10.0.0.0/8, 192.168.0.0/16 2001:db8::/32")
+func ParsePrefixes(s string) ([]Prefix, error) {
+	ss := strings.FieldsFunc(s, func(r rune) bool {
+		return r == ',' || unicode.IsSpace(r)
+	})
+	ps := make([]Prefix, 0, len(ss))
+	for _, s := range ss {
+		_, p, err := parse(s)
+		if err != nil {
+			return nil, err
+		}
+		ps = append(ps, *p)
+	}
+	return ps, nil
+}
+
+// ParsePrefixInto parses s as a single IP address or IP address
+// prefix and stores the result in dst.
+// It reuses the IP and Mask fields of dst when they have enough
+// capacity.
+//
+// Note that it only saves the allocation of the prefix and its
+// fields; parsing s still allocates temporary values in the same way
+// as ParsePrefix.
+func ParsePrefixInto(s string, dst *Prefix) error {
+	_, n, err := net.ParseCIDR(s)
+	if err == nil {
+		dst.IP = append(dst.IP[:0], n.IP.To16()...)
+		dst.Mask = append(dst.Mask[:0], n.Mask...)
+		return nil
+	}
+	ip := net.ParseIP(s)
+	if ip == nil {
+		return &net.AddrError{Err: "invalid address", Addr: s}
+	}
+	l := net.IPv6len
+	if ip.To4() != nil {
+		l = net.IPv4len
+	}
+	dst.IP = append(dst.IP[:0], ip.To16()...)
+	dst.Mask = dst.Mask[:0]
+	for i := 0; i < l; i++ {
+		dst.Mask = append(dst.Mask, 0xff)
+	}
+	return nil
+}
+
+// ParseSortKey parses s as a sort key that is returned by
+// Prefix.SortKey.
+func ParseSortKey(s string) (*Prefix, error) {
+	i := strings.IndexByte(s, '/')
+	if i < 0 {
+		return nil, &net.AddrError{Err: "invalid sort key", Addr: s}
+	}
+	l, err := strconv.Atoi(s[i+1:])
+	if err != nil {
+		return nil, &net.AddrError{Err: "invalid sort key", Addr: s}
+	}
+	var ip net.IP
+	z := IPv6PrefixLen
+	if ss := strings.Split(s[:i], "."); len(ss) == net.IPv4len {
+		ip, z = make(net.IP, net.IPv4len), IPv4PrefixLen
+		for j := range ss {
+			n, err := strconv.ParseUint(ss[j], 10, 8)
+			if err != nil {
+				return nil, &net.AddrError{Err: "invalid sort key", Addr: s}
+			}
+			ip[j] = byte(n)
+		}
+	} else {
+		ip = net.ParseIP(s[:i])
+	}
+	if ip == nil || l < 0 || l > z || z == IPv6PrefixLen && ip.To4() != nil {
+		return nil, &net.AddrError{Err: "invalid sort key", Addr: s}
+	}
+	return ipToPrefix(ip, l, z), nil
+}
+
 func parseMulti(s string) ([]Position, []Prefix, error) {
 	ss := strings.Split(s, ",")
 	var poss []Position
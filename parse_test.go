@@ -5,6 +5,7 @@
 package ipaddr_test
 
 import (
+	"net"
 	"reflect"
 	"testing"
 
@@ -132,3 +133,92 @@ func TestParse(t *testing.T) {
 		}
 	}
 }
+
+func TestParsePrefixes(t *testing.T) {
+	for i, tt := range []struct {
+		in  string
+		ps  []ipaddr.Prefix
+		err error
+	}{
+		{
+			"10.0.0.0/8, 192.168.0.0/16 2001:db8::/32",
+			toPrefixes([]string{
+				"10.0.0.0/8",
+				"192.168.0.0/16",
+				"2001:db8::/32",
+			}),
+			nil,
+		},
+		{
+			"2001:db8::1\t192.0.2.1,,198.51.100.0/24\n",
+			toPrefixes([]string{
+				"2001:db8::1/128",
+				"192.0.2.1/32",
+				"198.51.100.0/24",
+			}),
+			nil,
+		},
+		{
+			"",
+			[]ipaddr.Prefix{},
+			nil,
+		},
+		{
+			"10.0.0.0/8, 192.168.0.0/33",
+			nil,
+			&net.AddrError{Err: "invalid address", Addr: "192.168.0.0/33"},
+		},
+		{
+			"10.0.0.0/8 2001:db8::/32,example.com",
+			nil,
+			&net.AddrError{Err: "invalid address", Addr: "example.com"},
+		},
+	} {
+		ps, err := ipaddr.ParsePrefixes(tt.in)
+		if !reflect.DeepEqual(err, tt.err) {
+			t.Errorf("#%d: got %v; want %v", i, err, tt.err)
+			continue
+		}
+		if !reflect.DeepEqual(ps, tt.ps) {
+			t.Errorf("#%d: got %v; want %v", i, ps, tt.ps)
+		}
+	}
+}
+
+func TestParsePrefixInto(t *testing.T) {
+	dst := ipaddr.GetPrefix()
+	defer ipaddr.PutPrefix(dst)
+	for i, tt := range []struct {
+		in   string
+		want *ipaddr.Prefix
+	}{
+		{"2001:db8::1/64", toPrefix("2001:db8::/64")},
+		{"192.168.0.1/24", toPrefix("192.168.0.0/24")},
+		{"2001:db8::1", toPrefix("2001:db8::1/128")},
+		{"192.168.0.1", toPrefix("192.168.0.1/32")},
+		{"192.168.0.1/33", nil},
+	} {
+		err := ipaddr.ParsePrefixInto(tt.in, dst)
+		if tt.want == nil {
+			if err == nil {
+				t.Errorf("#%d: got %v; want an error", i, dst)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("#%d: %v", i, err)
+			continue
+		}
+		if !reflect.DeepEqual(dst, tt.want) {
+			t.Errorf("#%d: got %v; want %v", i, dst, tt.want)
+		}
+	}
+
+	var p ipaddr.Prefix
+	if err := ipaddr.ParsePrefixInto("203.0.113.0/24", &p); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(&p, toPrefix("203.0.113.0/24")) {
+		t.Errorf("got %v; want %v", &p, toPrefix("203.0.113.0/24"))
+	}
+}
@@ -0,0 +1,35 @@
+// Copyright 2015 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.
+
+package ipaddr
+
+import (
+	"net"
+	"sync"
+)
+
+var prefixPool = sync.Pool{
+	New: func() interface{} {
+		return &Prefix{IPNet: net.IPNet{IP: make(net.IP, net.IPv6len), Mask: make(net.IPMask, net.IPv6len)}}
+	},
+}
+
+// GetPrefix returns a prefix from the pool of prefixes.
+// The content of the returned prefix is undefined; it is usually
+// filled by ParsePrefixInto.
+//
+// The caller must release the prefix by PutPrefix when it's no longer
+// needed, and must not retain the prefix or any part of it, such as
+// the IP or Mask field, after the release.
+func GetPrefix() *Prefix {
+	return prefixPool.Get().(*Prefix)
+}
+
+// PutPrefix releases p to the pool of prefixes.
+func PutPrefix(p *Prefix) {
+	if p == nil || cap(p.IP) < net.IPv6len || cap(p.Mask) < net.IPv6len {
+		return
+	}
+	prefixPool.Put(p)
+}
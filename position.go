@@ -4,7 +4,10 @@
 
 package ipaddr
 
-import "net"
+import (
+	"net"
+	"strconv"
+)
 
 // A Position represents a position on IP address space.
 type Position struct {
@@ -12,6 +15,18 @@ type Position struct {
 	Prefix Prefix // IP address prefix
 }
 
+// IPNet returns the prefix of p as a net.IPNet.
+// It returns nil when p has no prefix.
+func (p *Position) IPNet() *net.IPNet {
+	if p.Prefix.IP == nil {
+		return nil
+	}
+	n := net.IPNet{IP: make(net.IP, len(p.Prefix.IP)), Mask: make(net.IPMask, len(p.Prefix.Mask))}
+	copy(n.IP, p.Prefix.IP)
+	copy(n.Mask, p.Prefix.Mask)
+	return &n
+}
+
 // IsBroadcast reports whether p is an IPv4 directed or limited
 // broadcast address.
 func (p *Position) IsBroadcast() bool {
@@ -23,3 +38,13 @@ func (p *Position) IsBroadcast() bool {
 func (p *Position) IsSubnetRouterAnycast() bool {
 	return !p.IP.IsUnspecified() && !p.IP.IsLoopback() && !p.IP.IsMulticast() && p.IP.To16() != nil && p.IP.To4() == nil && p.IP.Equal(p.Prefix.IP)
 }
+
+// String returns a text form of p, the IP address followed by a slash
+// and the length of the prefix, such as "192.0.2.1/24".
+// It returns the IP address alone when p has no prefix.
+func (p *Position) String() string {
+	if p.Prefix.IP == nil {
+		return p.IP.String()
+	}
+	return p.IP.String() + "/" + strconv.Itoa(p.Prefix.Len())
+}
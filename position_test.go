@@ -18,6 +18,35 @@ func toPosition(s1, s2 string) *ipaddr.Position {
 	return &ipaddr.Position{IP: net.ParseIP(s1), Prefix: *toPrefix(s2)}
 }
 
+func TestPositionIPNet(t *testing.T) {
+	for i, tt := range []struct {
+		in   *ipaddr.Position
+		want string
+	}{
+		{toPosition("192.168.0.1", "192.168.0.0/24"), "192.168.0.0/24"},
+		{toPosition("2001:db8::1", "2001:db8::/64"), "2001:db8::/64"},
+		{&ipaddr.Position{IP: net.ParseIP("192.168.0.1")}, ""},
+	} {
+		n := tt.in.IPNet()
+		if tt.want == "" {
+			if n != nil {
+				t.Errorf("#%d: got %v; want nil", i, n)
+			}
+			continue
+		}
+		if n.String() != tt.want {
+			t.Errorf("#%d: got %v; want %v", i, n, tt.want)
+		}
+		if !n.Contains(tt.in.IP) {
+			t.Errorf("#%d: %v must contain %v", i, n, tt.in.IP)
+		}
+		n.IP[0] ^= 0xff
+		if tt.in.Prefix.String() != tt.want {
+			t.Errorf("#%d: got %v; want %v", i, tt.in.Prefix, tt.want)
+		}
+	}
+}
+
 func TestPositionIsBroadcast(t *testing.T) {
 	for i, tt := range []struct {
 		in *ipaddr.Position
@@ -69,3 +98,18 @@ func TestPositionIsSubnetRouterAnycast(t *testing.T) {
 		}
 	}
 }
+
+func TestPositionString(t *testing.T) {
+	for i, tt := range []struct {
+		in   *ipaddr.Position
+		want string
+	}{
+		{toPosition("192.168.0.1", "192.168.0.0/24"), "192.168.0.1/24"},
+		{toPosition("2001:db8::1", "2001:db8::/64"), "2001:db8::1/64"},
+		{&ipaddr.Position{IP: net.ParseIP("192.168.0.1")}, "192.168.0.1"},
+	} {
+		if s := tt.in.String(); s != tt.want {
+			t.Errorf("#%d: got %v; want %v", i, s, tt.want)
+		}
+	}
+}
@@ -5,13 +5,18 @@
 package ipaddr
 
 import (
+	"bytes"
 	"encoding"
 	"encoding/binary"
+	"errors"
 	"fmt"
+	"io"
 	"math"
 	"math/big"
 	"math/bits"
 	"net"
+	"sort"
+	"strconv"
 )
 
 var (
@@ -28,11 +33,45 @@ const (
 	IPv6PrefixLen = 8 * net.IPv6len // maximum number of prefix length in bits
 )
 
+// A ChildGroup represents a subnetwork of a prefix returned by
+// GroupByChild.
+type ChildGroup struct {
+	Child Prefix // subnetwork
+
+	// Addrs calls fn for each IP address in Child, in ascending
+	// order, until fn returns false.
+	Addrs func(fn func(net.IP) bool)
+}
+
+// An IndexedPrefix represents an IP address prefix with its index.
+type IndexedPrefix struct {
+	Index  int    // zero-based index
+	Prefix Prefix // IP address prefix
+}
+
+// A TimelineSegment represents a segment of address space returned
+// by Timeline.
+type TimelineSegment struct {
+	Prefix Prefix // IP address prefix
+	Free   bool   // whether the prefix is not allocated
+}
+
 // A Prefix represents an IP address prefix.
 type Prefix struct {
 	net.IPNet
 }
 
+func (p *Prefix) bitLen() int {
+	if p.IP.To4() != nil {
+		return IPv4PrefixLen
+	}
+	return IPv6PrefixLen
+}
+
+func (p *Prefix) hostLen() int {
+	return p.bitLen() - p.Len()
+}
+
 func (p *Prefix) lastIPv4Int() ipv4Int {
 	return ipToIPv4Int(p.IP) | ipv4Int(^mask32(p.Len()))
 }
@@ -52,6 +91,117 @@ func (p *Prefix) lastIPv6Int() ipv6Int {
 	return i
 }
 
+// AFI returns the IANA address family number and name of p.
+// It returns 1 and "ipv4" for IPv4, and 2 and "ipv6" for IPv6.
+func (p *Prefix) AFI() (uint16, string) {
+	if p.IP.To4() != nil {
+		return 1, "ipv4"
+	}
+	if p.IP.To16() != nil && p.IP.To4() == nil {
+		return 2, "ipv6"
+	}
+	return 0, ""
+}
+
+// AddrAtFraction returns the address at the offset floor(f *
+// NumNodes()) from the network address of p.
+// The fraction f is clamped to [0, 1), so that the returned address
+// is always in p.
+func (p *Prefix) AddrAtFraction(f float64) net.IP {
+	n := p.NumNodes()
+	off := new(big.Int)
+	if f >= 1 {
+		off.Sub(n, big.NewInt(1))
+	} else if f > 0 {
+		x := new(big.Float).SetPrec(uint(p.bitLen()) + 64).SetInt(n)
+		x.Mul(x, big.NewFloat(f))
+		x.Int(off)
+	}
+	return intToIP(off.Add(off, ipToInt(p.IP)), p.bitLen())
+}
+
+// AddrReader returns a reader that reads a text form of IP addresses
+// in p, starting from begin, one address per line.
+// It starts from the first address of p when begin is nil.
+// It reads the addresses on demand and does not buffer the whole
+// address range of p.
+func (p *Prefix) AddrReader(begin net.IP) io.Reader {
+	return newAddrReader(p, begin)
+}
+
+// AppendString appends a text form of p, the same as String returns,
+// to b and returns the extended buffer.
+func (p *Prefix) AppendString(b []byte) []byte {
+	ip, m := p.IP, p.Mask
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+		if len(m) == net.IPv6len {
+			m = m[12:]
+		}
+	}
+	l, z := m.Size()
+	if len(ip) != len(m) || z == 0 {
+		return append(b, p.String()...)
+	}
+	b = appendIP(b, ip)
+	b = append(b, '/')
+	return strconv.AppendUint(b, uint64(l), 10)
+}
+
+// BigBounds returns the first and last addresses in the address
+// range of p as integers.
+func (p *Prefix) BigBounds() (start, end *big.Int) {
+	return ipToInt(p.IP), ipToInt(p.Last())
+}
+
+// CanSplit reports whether count prefixes of length subnetLen fit
+// within p.
+func (p *Prefix) CanSplit(count *big.Int, subnetLen int) bool {
+	if count.Sign() < 0 || subnetLen < p.Len() || subnetLen > p.bitLen() {
+		return false
+	}
+	n := new(big.Int).Lsh(big.NewInt(1), uint(subnetLen-p.Len()))
+	return count.Cmp(n) <= 0
+}
+
+// ContainedBlocks returns a list of all the prefixes of length
+// blockLen that are entirely within p.
+// It returns nil when blockLen is shorter than the length of p or
+// longer than the address length, or when p contains more than 2^17
+// such prefixes; use ContainedBlocksFunc for the latter.
+func (p *Prefix) ContainedBlocks(blockLen int) []Prefix {
+	if blockLen < p.Len() || blockLen > p.bitLen() {
+		return nil
+	}
+	return p.Subnets(blockLen - p.Len())
+}
+
+// ContainedBlocksFunc calls fn for each prefix of length blockLen
+// that is entirely within p, in ascending order.
+// It stops when fn returns false.
+func (p *Prefix) ContainedBlocksFunc(blockLen int, fn func(*Prefix) bool) {
+	if blockLen < p.Len() || blockLen > p.bitLen() {
+		return
+	}
+	lastFn := (*Prefix).lastIPv6Int
+	if p.IP.To4() != nil {
+		lastFn = (*Prefix).lastIPv4MappedIPv6Int
+	}
+	end := lastFn(p)
+	curr := ipToIPv6Int(p.IP.To16())
+	for {
+		b := &Prefix{IPNet: net.IPNet{IP: curr.ip(), Mask: net.CIDRMask(blockLen, p.bitLen())}}
+		if !fn(b) {
+			return
+		}
+		curr = lastFn(b)
+		if curr.cmp(&end) == 0 {
+			return
+		}
+		curr.incr()
+	}
+}
+
 // Contains reports whether q is a subnetwork of p.
 func (p *Prefix) Contains(q *Prefix) bool {
 	if p.IP.To4() != nil {
@@ -63,6 +213,12 @@ func (p *Prefix) Contains(q *Prefix) bool {
 	return false
 }
 
+// ContainsPrefix reports whether q is equal to or a subnetwork of p.
+// It returns false when p and q belong to different address families.
+func (p *Prefix) ContainsPrefix(q *Prefix) bool {
+	return p.Equal(q) || p.Contains(q)
+}
+
 func (p *Prefix) containsIPv4(q *Prefix) bool {
 	if q.IP.To4() == nil {
 		return false
@@ -102,6 +258,119 @@ func (p *Prefix) containsIPv6(q *Prefix) bool {
 	return bits.LeadingZeros64(fdiff[0]) >= l0 && bits.LeadingZeros64(fdiff[1]) >= l1 && bits.LeadingZeros64(ldiff[0]) >= l0 && bits.LeadingZeros64(ldiff[1]) >= l1
 }
 
+// gaps returns a list of prefixes that cover all the addresses in p
+// not covered by ps.
+func (p *Prefix) gaps(ps []Prefix) []Prefix {
+	var nps []Prefix
+	for i := range ps {
+		if ps[i].Equal(p) || ps[i].Contains(p) {
+			return nil
+		}
+		if p.Contains(&ps[i]) {
+			nps = append(nps, ps[i])
+		}
+	}
+	nps = newDisjointPrefixes(nps)
+	var end ipv6Int
+	if p.IP.To4() != nil {
+		end = p.lastIPv4MappedIPv6Int()
+	} else {
+		end = p.lastIPv6Int()
+	}
+	var gaps []Prefix
+	curr := ipToIPv6Int(p.IP.To16())
+	for i := range nps {
+		first := ipToIPv6Int(nps[i].IP.To16())
+		if curr.cmp(&first) < 0 {
+			last := first
+			last.decr()
+			gaps = append(gaps, Summarize(curr.ip(), last.ip())...)
+		}
+		if nps[i].IP.To4() != nil {
+			curr = nps[i].lastIPv4MappedIPv6Int()
+		} else {
+			curr = nps[i].lastIPv6Int()
+		}
+		if curr.cmp(&end) == 0 {
+			return gaps
+		}
+		curr.incr()
+	}
+	return append(gaps, Summarize(curr.ip(), end.ip())...)
+}
+
+// Edges returns up to n addresses from the beginning and up to n
+// addresses from the end of the address range of p, including the
+// network and broadcast addresses.
+// An address in first never appears in last when p contains fewer
+// than 2n addresses.
+func (p *Prefix) Edges(n int) (first, last []net.IP) {
+	if n <= 0 {
+		return nil, nil
+	}
+	nf, nl := n, n
+	if l := p.hostLen(); l < 63 {
+		total := 1 << uint(l)
+		if nf > total {
+			nf = total
+		}
+		if nl > total-nf {
+			nl = total - nf
+		}
+	}
+	var curr, end ipv6Int
+	curr = ipToIPv6Int(p.IP.To16())
+	if p.IP.To4() != nil {
+		end = p.lastIPv4MappedIPv6Int()
+	} else {
+		end = p.lastIPv6Int()
+	}
+	for i := 0; i < nf; i++ {
+		first = append(first, curr.ip())
+		curr.incr()
+	}
+	if nl == 0 {
+		return first, nil
+	}
+	last = make([]net.IP, nl)
+	for i := nl - 1; i >= 0; i-- {
+		last[i] = end.ip()
+		end.decr()
+	}
+	return first, last
+}
+
+// Embedded4 returns the IPv4 address embedded in p.
+// It extracts the address from 6to4 (2002::/16), Teredo (2001::/32),
+// IPv4-mapped (::ffff:0:0/96) and IPv4-compatible (::/96)
+// prefixes. For Teredo it returns the address of the client.
+// It returns false when p is not such a prefix or is too short to
+// carry the whole embedded address.
+func (p *Prefix) Embedded4() (net.IP, bool) {
+	l, z := p.Mask.Size()
+	if z != IPv6PrefixLen {
+		return nil, false
+	}
+	ip := p.IP.To16()
+	switch {
+	case ip[0] == 0x20 && ip[1] == 0x02:
+		if l < 48 {
+			return nil, false
+		}
+		return net.IPv4(ip[2], ip[3], ip[4], ip[5]), true
+	case ip[0] == 0x20 && ip[1] == 0x01 && ip[2] == 0 && ip[3] == 0:
+		if l < IPv6PrefixLen {
+			return nil, false
+		}
+		return net.IPv4(^ip[12], ^ip[13], ^ip[14], ^ip[15]), true
+	case l == IPv6PrefixLen && p.IP.To4() != nil:
+		return net.IPv4(ip[12], ip[13], ip[14], ip[15]), true
+	case l == IPv6PrefixLen && allZeros(ip[:12]) && !allZeros(ip[12:15]):
+		return net.IPv4(ip[12], ip[13], ip[14], ip[15]), true
+	}
+	return nil, false
+}
+
 // Equal reports whether p and q are equal.
 func (p *Prefix) Equal(q *Prefix) bool {
 	return compareAscending(p, q) == 0
@@ -135,301 +404,1619 @@ func (p *Prefix) Exclude(q *Prefix) []Prefix {
 	} else if r.Equal(q) {
 		ps = append(ps, *l)
 	}
-	return ps
+	return ps
+}
+
+// ExcludeAsRanges returns the address ranges of p that do not
+// contain q, one below and one above q at most.
+// It returns nil when p does not contain q.
+func (p *Prefix) ExcludeAsRanges(q *Prefix) []Range {
+	if !p.IPNet.Contains(q.IP) || q.Len() < p.Len() {
+		return nil
+	}
+	var rs []Range
+	if first, end := ipToIPv6Int(p.IP.To16()), ipToIPv6Int(q.IP.To16()); first.cmp(&end) < 0 {
+		end.decr()
+		rs = append(rs, Range{First: first.ip(), Last: end.ip()})
+	}
+	lastFn := (*Prefix).lastIPv6Int
+	if p.IP.To4() != nil {
+		lastFn = (*Prefix).lastIPv4MappedIPv6Int
+	}
+	if begin, last := lastFn(q), lastFn(p); begin.cmp(&last) < 0 {
+		begin.incr()
+		rs = append(rs, Range{First: begin.ip(), Last: last.ip()})
+	}
+	return rs
+}
+
+func subnetsIPv4(p *Prefix, reuse bool) (l *Prefix, r *Prefix) {
+	i := ipToIPv4Int(p.IP) | ipv4Int(1<<uint(IPv4PrefixLen-p.Len()-1))
+	r = i.prefix(p.Len()+1, IPv4PrefixLen)
+	if reuse {
+		l = p
+		binary.BigEndian.PutUint32(l.Mask, mask32(l.Len()+1))
+	} else {
+		l = ipToIPv4Int(p.IP).prefix(p.Len()+1, IPv4PrefixLen)
+	}
+	return
+}
+
+func subnetsIPv6(p *Prefix, reuse bool) (l *Prefix, r *Prefix) {
+	i := ipToIPv6Int(p.IP)
+	id := ipv6Int{0, 1}
+	id.lsh(IPv6PrefixLen - p.Len() - 1)
+	ii := ipv6Int{i[0] | id[0], i[1] | id[1]}
+	r = ii.prefix(p.Len()+1, IPv6PrefixLen)
+	if reuse {
+		l = p
+		var m ipv6Int
+		m.mask(l.Len() + 1)
+		binary.BigEndian.PutUint64(l.Mask[:8], m[0])
+		binary.BigEndian.PutUint64(l.Mask[8:16], m[1])
+	} else {
+		l = i.prefix(p.Len()+1, IPv6PrefixLen)
+	}
+	return
+}
+
+// FirstHost returns the first host-assignable IP address in p.
+func (p *Prefix) FirstHost() net.IP {
+	i := ipToIPv6Int(p.IP.To16())
+	if ip := i.ip(); p.isHostAssignable(ip, p.Last()) {
+		return ip
+	}
+	i.incr()
+	return i.ip()
+}
+
+// FirstOverlap returns the first prefix in others that overlaps with
+// p.
+// It returns false when no prefix in others overlaps with p.
+func (p *Prefix) FirstOverlap(others []Prefix) (*Prefix, bool) {
+	for i := range others {
+		if p.Overlaps(&others[i]) {
+			return clonePrefix(&others[i]), true
+		}
+	}
+	return nil, false
+}
+
+// GroupByChild returns a list of all the subnetworks of length
+// childLen in p, in ascending order, each of which enumerates its
+// addresses on demand.
+// It returns nil when childLen is shorter than the length of p or
+// longer than the address length, or when p contains more than 2^17
+// such subnetworks.
+func (p *Prefix) GroupByChild(childLen int) []ChildGroup {
+	ps := p.ContainedBlocks(childLen)
+	if ps == nil {
+		return nil
+	}
+	gs := make([]ChildGroup, len(ps))
+	for i := range ps {
+		child := &ps[i]
+		gs[i] = ChildGroup{Child: *child, Addrs: func(fn func(net.IP) bool) { child.walk(nil, fn) }}
+	}
+	return gs
+}
+
+// Hostmask returns a host mask, the inverse mask of p's network mask.
+func (p *Prefix) Hostmask() net.IPMask {
+	return invert(p.Mask)
+}
+
+// HostmaskBytes returns a host mask, the inverse mask of p's network
+// mask, as Hostmask does.
+// The returned slice is shared with other callers and must be treated
+// as read-only.
+func (p *Prefix) HostmaskBytes() []byte {
+	l, z := p.Mask.Size()
+	switch z {
+	case IPv4PrefixLen:
+		return ipv4Hostmasks[l]
+	case IPv6PrefixLen:
+		return ipv6Hostmasks[l]
+	}
+	return p.Hostmask()
+}
+
+// HostsExcluding returns a list of host-assignable IP addresses in p
+// that are not listed in reserved, starting from begin.
+// It starts from the first address of p when begin is nil.
+// It examines at most 2^17 addresses starting from begin; use
+// HostsFunc to enumerate the addresses of a larger prefix.
+func (p *Prefix) HostsExcluding(begin net.IP, reserved []net.IP) []net.IP {
+	excl := make(map[string]bool, len(reserved))
+	for _, ip := range reserved {
+		if ip = ip.To16(); ip != nil {
+			excl[string(ip)] = true
+		}
+	}
+	var ips []net.IP
+	p.hosts(begin, func(ip net.IP) bool {
+		if !excl[string(ip)] {
+			ips = append(ips, ip)
+		}
+		return true
+	})
+	return ips
+}
+
+// HostsFunc calls fn for each host-assignable IP address in p,
+// starting from begin, in ascending order, until fn returns false.
+// Like HostsExcluding, it excludes the IPv4 network and directed
+// broadcast addresses and the IPv6 subnet-router anycast address.
+// Unlike HostsExcluding, it does not limit the number of addresses,
+// but calls fn for no address when the length of p is 0.
+// It starts from the first address of p when begin is nil.
+func (p *Prefix) HostsFunc(begin net.IP, fn func(net.IP) bool) {
+	if p.Len() == 0 {
+		return
+	}
+	last := p.Last()
+	p.walk(begin, func(ip net.IP) bool {
+		if !p.isHostAssignable(ip, last) {
+			return true
+		}
+		return fn(ip)
+	})
+}
+
+// HostsMatching returns a list of host-assignable IP addresses in p,
+// starting from begin, whose bit field of nbits bits at the bit
+// position pos, counted from the most significant bit of the address,
+// equals value.
+// It starts from the first address of p when begin is nil.
+// It examines at most 2^17 addresses starting from begin.
+// It returns nil when the bit field is out of range.
+func (p *Prefix) HostsMatching(begin net.IP, pos, nbits int, value uint32) []net.IP {
+	if pos < 0 || nbits < 1 || nbits > 32 || pos+nbits > p.bitLen() {
+		return nil
+	}
+	var ips []net.IP
+	p.hosts(begin, func(ip net.IP) bool {
+		b := ip.To16()
+		if p.IP.To4() != nil {
+			b = ip.To4()
+		}
+		if bitField(b, pos, nbits) == value {
+			ips = append(ips, ip)
+		}
+		return true
+	})
+	return ips
+}
+
+// HostsStrict is like HostsExcluding with no reserved addresses but
+// also excludes the IPv6 reserved subnet anycast addresses described
+// in RFC 2526.
+// It examines at most 2^17 addresses starting from begin.
+func (p *Prefix) HostsStrict(begin net.IP) []net.IP {
+	var ips []net.IP
+	p.hosts(begin, func(ip net.IP) bool {
+		if !p.isReservedSubnetAnycast(ip) {
+			ips = append(ips, ip)
+		}
+		return true
+	})
+	return ips
+}
+
+// maxHostScan is the maximum number of addresses examined by the
+// functions returning a list of host-assignable addresses.
+const maxHostScan = 1 << 17 // don't bother runtime.growslice by big numbers
+
+// hosts calls fn for each host-assignable IP address in p among the
+// first maxHostScan addresses starting from begin.
+func (p *Prefix) hosts(begin net.IP, fn func(net.IP) bool) {
+	if p.Len() == 0 {
+		return
+	}
+	n, last := 0, p.Last()
+	p.walk(begin, func(ip net.IP) bool {
+		if n++; n > maxHostScan {
+			return false
+		}
+		if !p.isHostAssignable(ip, last) {
+			return true
+		}
+		return fn(ip)
+	})
+}
+
+// isHostAssignable reports whether ip, an address in p whose last
+// address is last, is assignable to a host.
+// It excludes the IPv4 network and directed broadcast addresses
+// except on /31 and /32 prefixes, and the IPv6 subnet-router anycast
+// address except on /128 prefixes.
+func (p *Prefix) isHostAssignable(ip, last net.IP) bool {
+	if p.IP.To4() != nil {
+		if p.Len() >= IPv4PrefixLen-1 {
+			return true
+		}
+		return !ip.Equal(p.IP) && !ip.Equal(last)
+	}
+	if p.Len() == IPv6PrefixLen {
+		return true
+	}
+	return !ip.Equal(p.IP)
+}
+
+// isReservedSubnetAnycast reports whether ip, an address in p, is an
+// IPv6 reserved subnet anycast address, one of the highest 128
+// interface identifiers in the subnet.
+// It ignores subnets that consist of fewer than 256 addresses.
+// For a prefix not longer than 64 bits, the interface identifiers are
+// in the modified EUI-64 format and the universal/local bit is
+// cleared.
+func (p *Prefix) isReservedSubnetAnycast(ip net.IP) bool {
+	if p.IP.To4() != nil || p.Len() > IPv6PrefixLen-8 {
+		return false
+	}
+	i := ipToIPv6Int(ip.To16())
+	if p.Len() <= 64 {
+		return i[1] >= 0xfdffffffffffff80 && i[1] <= 0xfdffffffffffffff
+	}
+	last := p.lastIPv6Int()
+	return i[0] == last[0] && i[1]|0x7f == last[1]
+}
+
+// walk calls fn for each IP address in p, starting from begin, until
+// fn returns false.
+func (p *Prefix) walk(begin net.IP, fn func(net.IP) bool) {
+	if begin == nil {
+		begin = p.IP
+	}
+	if !p.IPNet.Contains(begin) {
+		return
+	}
+	var end ipv6Int
+	if p.IP.To4() != nil {
+		end = p.lastIPv4MappedIPv6Int()
+	} else {
+		end = p.lastIPv6Int()
+	}
+	curr := ipToIPv6Int(begin.To16())
+	for fn(curr.ip()) && curr.cmp(&end) != 0 {
+		curr.incr()
+	}
+}
+
+// IsByteAligned reports whether the length of p is a multiple of 8.
+func (p *Prefix) IsByteAligned() bool {
+	return p.Len()%8 == 0
+}
+
+// IsNibbleAligned reports whether the length of p is a multiple of 4.
+func (p *Prefix) IsNibbleAligned() bool {
+	return p.Len()%4 == 0
+}
+
+// IsOctetAligned is the same as IsByteAligned.
+func (p *Prefix) IsOctetAligned() bool {
+	return p.IsByteAligned()
+}
+
+// Last returns the last IP in the address range of p.
+// It returns the address of p when p contains only one address.
+func (p *Prefix) Last() net.IP {
+	if p.IP.To4() != nil {
+		i := p.lastIPv4Int()
+		return i.ip()
+	}
+	if p.IP.To16() != nil && p.IP.To4() == nil {
+		i := p.lastIPv6Int()
+		return i.ip()
+	}
+	return nil
+}
+
+// LastHost returns the last host-assignable IP address in p.
+func (p *Prefix) LastHost() net.IP {
+	last := p.Last()
+	if p.isHostAssignable(last, last) {
+		return last
+	}
+	i := ipToIPv6Int(last.To16())
+	i.decr()
+	return i.ip()
+}
+
+// Len returns the length of p in bits.
+func (p *Prefix) Len() int {
+	l, _ := p.Mask.Size()
+	return l
+}
+
+// MarshalBinary returns a BGP NLRI binary form of p.
+func (p *Prefix) MarshalBinary() ([]byte, error) {
+	ip := p.IP
+	if p.IP.To4() != nil {
+		ip = p.IP.To4()
+	}
+	var b [1 + net.IPv6len]byte
+	n := p.Len()
+	l := ((n + 8 - 1) &^ (8 - 1)) / 8
+	b[0] = byte(n)
+	l++
+	copy(b[1:l], ip)
+	return b[:l], nil
+}
+
+// MarshalText returns a UTF-8-encoded text form of p.
+func (p *Prefix) MarshalText() ([]byte, error) {
+	return []byte(p.String()), nil
+}
+
+// NumNodes returns the number of IP node addresses in p.
+func (p *Prefix) NumNodes() *big.Int {
+	i := new(big.Int).SetBytes(invert(p.Mask))
+	return i.Add(i, big.NewInt(1))
+}
+
+// Offset returns the offset of ip from the network address of p.
+// It returns false when p does not contain ip.
+func (p *Prefix) Offset(ip net.IP) (*big.Int, bool) {
+	if !p.IPNet.Contains(ip) || (p.IP.To4() != nil) != (ip.To4() != nil) {
+		return nil, false
+	}
+	off := ipToInt(ip)
+	return off.Sub(off, ipToInt(p.IP)), true
+}
+
+// Overlaps reports whether p overlaps with q.
+func (p *Prefix) Overlaps(q *Prefix) bool {
+	return p.Contains(q) || q.Contains(p) || p.Equal(q)
+}
+
+// ReservedAnycast returns the range of the IPv6 reserved subnet
+// anycast addresses described in RFC 2526 in p.
+// For a prefix shorter than /64, in which each /64 subnet has its own
+// reserved addresses, it returns the range in the last /64 subnet of
+// p.
+// It returns false when p is an IPv4 prefix or consists of fewer than
+// 256 addresses.
+func (p *Prefix) ReservedAnycast() (Range, bool) {
+	if p.IP.To4() != nil || p.hostLen() < 8 {
+		return Range{}, false
+	}
+	last := p.lastIPv6Int()
+	if p.Len() <= 64 {
+		last[1] = 0xfdffffffffffffff
+	}
+	first := ipv6Int{last[0], last[1] &^ 0x7f}
+	return Range{First: first.ip(), Last: last.ip()}, true
+}
+
+// SafeSupernet returns the shortest supernet of p, or p itself, that
+// does not overlap with any prefix in forbidden.
+// It returns nil when p overlaps with a prefix in forbidden.
+func (p *Prefix) SafeSupernet(forbidden []Prefix) *Prefix {
+	overlaps := func(q *Prefix) bool {
+		for i := range forbidden {
+			if q.Overlaps(&forbidden[i]) {
+				return true
+			}
+		}
+		return false
+	}
+	if overlaps(p) {
+		return nil
+	}
+	l := p.Len()
+	for l > 0 && !overlaps(ipToPrefix(p.IP, l-1, p.bitLen())) {
+		l--
+	}
+	return ipToPrefix(p.IP, l, p.bitLen())
+}
+
+// SiblingSpace returns a list of prefixes that cover the immediate
+// supernet of p except p, which is the sibling of p.
+// It returns nil when the length of p is 0.
+func (p *Prefix) SiblingSpace() []Prefix {
+	if p.Len() == 0 {
+		return nil
+	}
+	return ipToPrefix(p.IP, p.Len()-1, p.bitLen()).Exclude(p)
+}
+
+// SnapLen returns a prefix which has the same network address as p
+// and whose length is the multiple of boundary nearest to the length
+// of p, rounded down or rounded up when up is true.
+// Rounding down clears the address bits that exceed the new length.
+// It returns a copy of p when boundary is not positive.
+func (p *Prefix) SnapLen(boundary int, up bool) *Prefix {
+	l, z := p.Len(), p.bitLen()
+	if boundary > 0 {
+		if r := l % boundary; r != 0 {
+			l -= r
+			if up {
+				l += boundary
+			}
+		}
+		if l > z {
+			l = z
+		}
+	}
+	return ipToPrefix(p.IP, l, z)
+}
+
+// SortKey returns a fixed-width text form of p, such as
+// "192.168.000.000/024" for IPv4 or
+// "2001:0db8:0000:0000:0000:0000:0000:0000/032" for IPv6.
+// The lexicographic order of sort keys of the same address family is
+// the same as the order determined by Compare.
+func (p *Prefix) SortKey() string {
+	if ip := p.IP.To4(); ip != nil {
+		return fmt.Sprintf("%03d.%03d.%03d.%03d/%03d", ip[0], ip[1], ip[2], ip[3], p.Len())
+	}
+	b := make([]byte, 0, 8*5+4)
+	for i := 0; i < net.IPv6len; i += 2 {
+		if i > 0 {
+			b = append(b, ':')
+		}
+		b = append(b, fmt.Sprintf("%02x%02x", p.IP[i], p.IP[i+1])...)
+	}
+	return string(append(b, fmt.Sprintf("/%03d", p.Len())...))
+}
+
+// SpecialAddrs returns the addresses in p that have special meaning
+// and are not assignable to hosts, keyed by name.
+// For an IPv4 prefix, they are the "network" and "broadcast"
+// addresses, except on /31 and /32 prefixes.
+// For an IPv6 prefix, it is the "subnet-router-anycast" address,
+// except on /128 prefixes; use ReservedAnycast for the reserved
+// subnet anycast addresses.
+func (p *Prefix) SpecialAddrs() map[string]net.IP {
+	m := make(map[string]net.IP)
+	if p.IP.To4() != nil {
+		if p.hostLen() > 1 {
+			m["network"] = p.IP.To16()
+			m["broadcast"] = p.Last()
+		}
+		return m
+	}
+	if p.hostLen() > 0 {
+		m["subnet-router-anycast"] = p.IP.To16()
+	}
+	return m
+}
+
+// SplitAt returns the lower and upper halves of p, and the first
+// address of the upper half as the pivot.
+// It returns false when p cannot be split.
+func (p *Prefix) SplitAt() (lower, upper *Prefix, pivot net.IP, ok bool) {
+	if p.Len() == p.bitLen() {
+		return nil, nil, nil, false
+	}
+	subsFn := subnetsIPv6
+	if p.IP.To4() != nil {
+		subsFn = subnetsIPv4
+	}
+	lower, upper = subsFn(p, false)
+	pivot = make(net.IP, len(upper.IP))
+	copy(pivot, upper.IP)
+	return lower, upper, pivot, true
+}
+
+// SplitByRatio splits p into contiguous subnetworks whose sizes
+// approximate the ratios of the address space of p, and returns the
+// list of subnetworks in the order of ratios.
+// Each size is rounded to the nearest power of two on the
+// logarithmic scale, and when the rounded sizes exceed p, the size
+// that overshoots its ratio most is halved until the subnetworks fit
+// within p.
+// It returns an error when a ratio is not positive, ratios do not sum
+// to 1, or p is too small to hold all the subnetworks.
+func (p *Prefix) SplitByRatio(ratios []float64) ([]Prefix, error) {
+	if len(ratios) == 0 {
+		return nil, errors.New("no ratios")
+	}
+	var sum float64
+	ls := make([]int, len(ratios))
+	for i, r := range ratios {
+		if !(r > 0) {
+			return nil, errors.New("invalid ratio")
+		}
+		sum += r
+		l := p.Len() + int(math.Round(-math.Log2(r)))
+		if l < p.Len() {
+			l = p.Len()
+		}
+		if l > p.bitLen() {
+			l = p.bitLen()
+		}
+		ls[i] = l
+	}
+	if math.Abs(sum-1) > 1e-6 {
+		return nil, errors.New("ratios must sum to 1")
+	}
+	frac := func(i int) float64 { return math.Ldexp(1, p.Len()-ls[i]) }
+	for {
+		var total float64
+		for i := range ls {
+			total += frac(i)
+		}
+		if total <= 1 {
+			break
+		}
+		j := -1
+		for i := range ls {
+			if ls[i] < p.bitLen() && (j < 0 || frac(i)/ratios[i] > frac(j)/ratios[j]) {
+				j = i
+			}
+		}
+		if j < 0 {
+			return nil, errors.New("prefix too small")
+		}
+		ls[j]++
+	}
+	idx := make([]int, len(ls))
+	for i := range idx {
+		idx[i] = i
+	}
+	sort.SliceStable(idx, func(i, j int) bool { return ls[idx[i]] < ls[idx[j]] })
+	lastFn := (*Prefix).lastIPv6Int
+	if p.IP.To4() != nil {
+		lastFn = (*Prefix).lastIPv4MappedIPv6Int
+	}
+	ps := make([]Prefix, len(ls))
+	ip := p.IP
+	for _, i := range idx {
+		ps[i] = *ipToPrefix(ip, ls[i], p.bitLen())
+		last := lastFn(&ps[i])
+		last.incr()
+		ip = last.ip()
+	}
+	return ps, nil
+}
+
+func (p Prefix) String() string {
+	return p.IPNet.String()
+}
+
+// SubnetAt returns the i'th prefix of the list of prefixes that are
+// split from p by n, as Subnets does, without making the list.
+func (p *Prefix) SubnetAt(n int, i *big.Int) (*Prefix, error) {
+	if n < 0 || n > p.hostLen() {
+		return nil, errors.New("invalid number of subnetworks")
+	}
+	if i.Sign() < 0 || i.BitLen() > n {
+		return nil, errors.New("subnetwork index out of range")
+	}
+	if p.IP.To4() != nil {
+		ii := ipToIPv4Int(p.IP) | ipv4Int(i.Uint64()<<uint(IPv4PrefixLen-p.Len()-n))
+		return ii.prefix(p.Len()+n, IPv4PrefixLen), nil
+	}
+	var b [net.IPv6len]byte
+	id := ipToIPv6Int(i.FillBytes(b[:]))
+	id.lsh(IPv6PrefixLen - p.Len() - n)
+	x := ipToIPv6Int(p.IP)
+	ii := ipv6Int{x[0] | id[0], x[1] | id[1]}
+	return ii.prefix(p.Len()+n, IPv6PrefixLen), nil
+}
+
+// SubnetHistogram returns the numbers of addresses in ips that fall
+// in each subnetwork of p, which are split from p as Subnets does.
+// It ignores addresses that are not in p.
+// It returns nil when n is out of range.
+func (p *Prefix) SubnetHistogram(ips []net.IP, n int) []int {
+	if 0 > n || n > 17 || p.Len()+n > p.bitLen() {
+		return nil
+	}
+	hist := make([]int, 1<<uint(n))
+	for _, ip := range ips {
+		if !p.IPNet.Contains(ip) {
+			continue
+		}
+		b := ip.To16()
+		if p.IP.To4() != nil {
+			b = ip.To4()
+		}
+		hist[bitField(b, p.Len(), n)]++
+	}
+	return hist
+}
+
+// SubnetLevels returns lists of prefixes for each level of recursive
+// subdivision from p down to the prefix length targetLen.
+// The list at level 0 consists of p and the list at level n consists
+// of the subnetworks of p with the prefix length p.Len() + n.
+// It returns nil when targetLen is out of range or the subdivision
+// produces too many prefixes.
+func (p *Prefix) SubnetLevels(targetLen int) [][]Prefix {
+	n := targetLen - p.Len()
+	if 0 > n || targetLen > p.bitLen() || n > 17 {
+		return nil
+	}
+	levels := make([][]Prefix, n+1)
+	for i := range levels {
+		levels[i] = p.Subnets(i)
+	}
+	return levels
+}
+
+// Subnets returns a list of prefixes that are split from p, into
+// small address blocks by n which represents a number of subnetworks
+// in the power of 2 notation.
+func (p *Prefix) Subnets(n int) []Prefix {
+	if 0 > n || n > 17 { // don't bother runtime.makeslice by big numbers
+		return nil
+	}
+	ps := make([]Prefix, 1<<uint(n))
+	if p.IP.To4() != nil {
+		x := ipToIPv4Int(p.IP)
+		off := uint(IPv4PrefixLen - p.Len() - n)
+		for i := range ps {
+			ii := x | ipv4Int(i<<off)
+			ps[i] = *ii.prefix(p.Len()+n, IPv4PrefixLen)
+		}
+		return ps
+	}
+	x := ipToIPv6Int(p.IP)
+	off := IPv6PrefixLen - p.Len() - n
+	for i := range ps {
+		id := ipv6Int{0, uint64(i)}
+		id.lsh(off)
+		ii := ipv6Int{x[0] | id[0], x[1] | id[1]}
+		ps[i] = *ii.prefix(p.Len()+n, IPv6PrefixLen)
+	}
+	return ps
+}
+
+// SubnetsIndexed returns a list of prefixes that are split from p as
+// Subnets does, with their zero-based indices.
+func (p *Prefix) SubnetsIndexed(n int) []IndexedPrefix {
+	ps := p.Subnets(n)
+	if ps == nil {
+		return nil
+	}
+	ips := make([]IndexedPrefix, len(ps))
+	for i := range ps {
+		ips[i] = IndexedPrefix{Index: i, Prefix: ps[i]}
+	}
+	return ips
+}
+
+// SubnetsIndexedFunc calls fn for each prefix that is split from p,
+// into small address blocks by n which represents a number of
+// subnetworks in the power of 2 notation, with its zero-based index
+// in ascending order.
+// It stops when fn returns false.
+// It does nothing when n is negative or greater than
+// strconv.IntSize-1, beyond which the indices overflow int.
+func (p *Prefix) SubnetsIndexedFunc(n int, fn func(i int, sub *Prefix) bool) {
+	if 0 > n || n > strconv.IntSize-1 {
+		return
+	}
+	i := 0
+	p.ContainedBlocksFunc(p.Len()+n, func(sub *Prefix) bool {
+		ok := fn(i, sub)
+		i++
+		return ok
+	})
+}
+
+// Summary returns the network address, the first and last
+// host-assignable addresses, and the directed broadcast address of p.
+// The broadcast address is nil when p is an IPv6 prefix, or an IPv4
+// prefix whose length is 31 or 32.
+func (p *Prefix) Summary() (network, first, last, broadcast net.IP) {
+	lastFn := (*Prefix).lastIPv6Int
+	if p.IP.To4() != nil {
+		lastFn = (*Prefix).lastIPv4MappedIPv6Int
+	}
+	i, j := ipToIPv6Int(p.IP.To16()), lastFn(p)
+	network, broadcast = i.ip(), j.ip()
+	switch {
+	case p.IP.To4() == nil:
+		if p.hostLen() > 0 {
+			i.incr()
+		}
+		broadcast = nil
+	case p.hostLen() > 1:
+		i.incr()
+		j.decr()
+	default:
+		broadcast = nil
+	}
+	first, last = i.ip(), j.ip()
+	return
+}
+
+// UnmarshalBinary replaces p with the BGP NLRI binary form b.
+func (p *Prefix) UnmarshalBinary(b []byte) error {
+	if p.IP.To4() != nil {
+		binary.BigEndian.PutUint32(p.Mask, mask32(int(b[0])))
+		copy(p.IP, net.IPv4zero)
+		copy(p.IP.To4(), b[1:])
+	}
+	if p.IP.To16() != nil && p.IP.To4() == nil {
+		var m ipv6Int
+		m.mask(int(b[0]))
+		binary.BigEndian.PutUint64(p.Mask[:8], m[0])
+		binary.BigEndian.PutUint64(p.Mask[8:16], m[1])
+		copy(p.IP, net.IPv6unspecified)
+		copy(p.IP, b[1:])
+	}
+	return nil
+}
+
+// UnmarshalText replaces p with txt.
+func (p *Prefix) UnmarshalText(txt []byte) error {
+	_, n, err := net.ParseCIDR(string(txt))
+	if err != nil {
+		return err
+	}
+	copy(p.IP.To16(), n.IP.To16())
+	copy(p.Mask, n.Mask)
+	return nil
+}
+
+// AdjacencyEdges returns a list of pairs of indices of a parent
+// prefix and its child prefix in ps, as Parents computes, in order of
+// the index of the child prefix.
+// Each pair consists of the index of the longest prefix in ps that
+// contains a prefix and the index of the contained prefix.
+func AdjacencyEdges(ps []Prefix) [][2]int {
+	var edges [][2]int
+	for i, parent := range Parents(ps) {
+		if parent >= 0 {
+			edges = append(edges, [2]int{parent, i})
+		}
+	}
+	return edges
+}
+
+// Aggregate aggregates ps and returns a list of aggregated prefixes.
+// The aggregated prefixes cover exactly the same addresses as ps; a
+// prefix contained in another prefix is dropped, and neighboring
+// prefixes are merged into their supernet.
+// The list consists of aggregated IPv4 prefixes followed by
+// aggregated IPv6 prefixes, each in ascending order.
+// It returns nil when ps is empty.
+func Aggregate(ps []Prefix) []Prefix {
+	ipv4, ipv6 := AggregateSafe(ps)
+	return append(ipv4, ipv6...)
+}
+
+// aggregateFamily aggregates ps, a list of prefixes that belong to the
+// same address family.
+func aggregateFamily(ps []Prefix) []Prefix {
+	if len(ps) == 0 {
+		return nil
+	}
+	rs := make([]Range, len(ps))
+	for i := range ps {
+		rs[i] = Range{First: ps[i].IP, Last: ps[i].Last()}
+	}
+	aggrs, _ := SummarizeAll(rs)
+	if Debug {
+		checkAggregate(ps, aggrs)
+	}
+	return aggrs
+}
+
+// AggregatableGroups returns groups of prefixes in ps that Aggregate
+// would merge into a single prefix, without merging them.
+// A prefix that cannot be merged with any other prefix forms a group
+// of its own. The groups are in ascending order of the aggregated
+// prefixes, IPv4 groups first.
+func AggregatableGroups(ps []Prefix) [][]Prefix {
+	var ps4, ps6 []Prefix
+	for i := range ps {
+		if ps[i].IP.To4() != nil {
+			ps4 = append(ps4, ps[i])
+		} else {
+			ps6 = append(ps6, ps[i])
+		}
+	}
+	return append(aggregatableGroups(ps4), aggregatableGroups(ps6)...)
+}
+
+func aggregatableGroups(ps []Prefix) [][]Prefix {
+	ps = newSortedPrefixes(ps, sortAscending, false)
+	rs := make([]Range, len(ps))
+	for i := range ps {
+		rs[i] = Range{First: ps[i].IP, Last: ps[i].Last()}
+	}
+	supers, _ := SummarizeAll(rs)
+	var groups [][]Prefix
+	i := 0
+	for j := range supers {
+		var group []Prefix
+		for ; i < len(ps) && (supers[j].Equal(&ps[i]) || supers[j].Contains(&ps[i])); i++ {
+			group = append(group, ps[i])
+		}
+		groups = append(groups, group)
+	}
+	return groups
+}
+
+// AggregateBGP aggregates ps as Aggregate does, and then merges two
+// neighboring aggregated prefixes of the same address family into
+// their shortest common prefix while more than half of the addresses
+// in the common prefix are covered by ps.
+// The merge policy is specific to this package; BGP itself does not
+// prescribe when a speaker aggregates routes.
+// It also returns whether each aggregated prefix covers addresses
+// that are not covered by the input prefixes it aggregates, which
+// requires the ATOMIC_AGGREGATE path attribute described in RFC 4271.
+func AggregateBGP(ps []Prefix) (aggregated []Prefix, atomic []bool) {
+	in := ps
+	ps = Aggregate(ps)
+	for len(ps) > 1 {
+		super, gap := closestMerge(ps)
+		if super == nil || gap.Lsh(gap, 1).Cmp(super.NumNodes()) >= 0 {
+			break
+		}
+		nps := Aggregate(append(ps, *super))
+		if len(nps) >= len(ps) {
+			break
+		}
+		ps = nps
+	}
+	atomic = make([]bool, len(ps))
+	for i := range ps {
+		atomic[i] = coveredNodes(&ps[i], in).Cmp(ps[i].NumNodes()) < 0
+	}
+	return ps, atomic
+}
+
+// AggregateSafe aggregates the IPv4 and IPv6 prefixes in ps
+// separately, and returns the lists of aggregated prefixes for each
+// address family in ascending order.
+// A list is nil when ps contains no prefix of the address family.
+func AggregateSafe(ps []Prefix) (ipv4, ipv6 []Prefix) {
+	return aggregateFamily(byAddrFamily(ps).newIPv4Prefixes()), aggregateFamily(byAddrFamily(ps).newIPv6Prefixes())
+}
+
+// AggregateStats is like Aggregate but also returns the number of
+// distinct input prefixes taken into account and the number of
+// aggregated prefixes.
+func AggregateStats(ps []Prefix) (aggregated []Prefix, inputCount, outputCount int) {
+	inputCount = len(Canonical(ps))
+	aggregated = Aggregate(ps)
+	return aggregated, inputCount, len(aggregated)
+}
+
+// AggregateToBytes aggregates ps as Aggregate does, and then merges
+// two neighboring aggregated prefixes into their shortest common
+// prefix until the size of the prefixes in the compact NLRI encoding,
+// which is 1 plus the minimum number of bytes that hold the prefix
+// bits for each prefix, is less than or equal to maxBytes.
+// It merges the neighbors that cover the smallest number of addresses
+// not covered by ps first.
+//
+// Note that the returned list may cover addresses that are not
+// covered by ps, and may not fit within maxBytes when no more
+// prefixes can be merged, because it never merges prefixes of
+// different address families or neighbors whose shortest common
+// prefix is of length 0.
+func AggregateToBytes(ps []Prefix, maxBytes int) []Prefix {
+	size := func(ps []Prefix) int {
+		n := 0
+		for i := range ps {
+			n += 1 + (ps[i].Len()+7)/8
+		}
+		return n
+	}
+	ps = Aggregate(ps)
+	for size(ps) > maxBytes && len(ps) > 1 {
+		super, _ := closestMerge(ps)
+		if super == nil {
+			break
+		}
+		nps := Aggregate(append(ps, *super))
+		if len(nps) >= len(ps) {
+			break
+		}
+		ps = nps
+	}
+	return ps
+}
+
+// AggregateToLimit aggregates ps as Aggregate does, and then merges
+// two neighboring aggregated prefixes into their shortest common
+// prefix until the number of prefixes is less than or equal to maxN.
+// It merges the neighbors that cover the smallest number of addresses
+// not covered by ps first.
+// A maxN less than 1 is treated as 1.
+//
+// Note that the returned list may cover addresses that are not
+// covered by ps, and may contain more than maxN prefixes because it
+// never merges prefixes of different address families or neighbors
+// whose shortest common prefix is of length 0.
+func AggregateToLimit(ps []Prefix, maxN int) []Prefix {
+	ps = Aggregate(ps)
+	for len(ps) > maxN && len(ps) > 1 {
+		super, _ := closestMerge(ps)
+		if super == nil {
+			break
+		}
+		nps := Aggregate(append(ps, *super))
+		if len(nps) >= len(ps) {
+			break
+		}
+		ps = nps
+	}
+	return ps
+}
+
+// AggregateWithGap aggregates ps as Aggregate does, and then merges
+// two neighboring aggregated prefixes into their shortest common
+// prefix while the number of addresses between the two prefixes,
+// which are not covered by ps, is less than or equal to maxGap.
+// It merges the neighbors with the smallest gap first, and never
+// merges neighbors without any gap between them.
+//
+// Note that the returned list may cover addresses that are not
+// covered by ps: the addresses in the gaps, and the addresses in the
+// shortest common prefixes outside the merged neighbors and the gaps.
+func AggregateWithGap(ps []Prefix, maxGap *big.Int) []Prefix {
+	ps = Aggregate(ps)
+	if maxGap == nil || maxGap.Sign() <= 0 {
+		return ps
+	}
+	for len(ps) > 1 {
+		super, gap := closestNeighbors(ps)
+		if super == nil || gap.Cmp(maxGap) > 0 {
+			break
+		}
+		ps = Aggregate(append(ps, *super))
+	}
+	return ps
+}
+
+// closestNeighbors returns the shortest common prefix of two
+// neighboring prefixes of the same address family in ps, a list of
+// aggregated prefixes, that are separated by the smallest positive
+// number of addresses, and the number of such addresses.
+func closestNeighbors(ps []Prefix) (*Prefix, *big.Int) {
+	var best *Prefix
+	var bestGap *big.Int
+	one := big.NewInt(1)
+	for i := 0; i < len(ps)-1; i++ {
+		if (ps[i].IP.To4() != nil) != (ps[i+1].IP.To4() != nil) {
+			continue
+		}
+		gap := new(big.Int).Sub(ipToInt(ps[i+1].IP), ipToInt(ps[i].Last()))
+		if gap.Sub(gap, one).Sign() <= 0 {
+			continue
+		}
+		if best == nil || gap.Cmp(bestGap) < 0 {
+			best, bestGap = Supernet(ps[i:i+2]), gap
+		}
+	}
+	return best, bestGap
+}
+
+// closestMerge returns the shortest common prefix of two neighboring
+// prefixes in ps that covers the smallest number of addresses not
+// covered by ps, and the number of such addresses.
+func closestMerge(ps []Prefix) (*Prefix, *big.Int) {
+	var best *Prefix
+	var bestGap *big.Int
+	for i := 0; i < len(ps)-1; i++ {
+		if (ps[i].IP.To4() != nil) != (ps[i+1].IP.To4() != nil) {
+			continue
+		}
+		super := Supernet(ps[i : i+2])
+		if super == nil {
+			continue
+		}
+		gap := super.NumNodes()
+		for j := range ps {
+			if super.Equal(&ps[j]) || super.Contains(&ps[j]) {
+				gap.Sub(gap, ps[j].NumNodes())
+			}
+		}
+		if best == nil || gap.Cmp(bestGap) < 0 {
+			best, bestGap = super, gap
+		}
+	}
+	return best, bestGap
+}
+
+// BestMatch returns the longest prefix in ps that contains ip, and
+// its length.
+// It returns false when no prefix in ps contains ip.
+func BestMatch(ip net.IP, ps []Prefix) (*Prefix, int, bool) {
+	best := -1
+	for i := range ps {
+		if !ps[i].IPNet.Contains(ip) {
+			continue
+		}
+		if best < 0 || ps[i].Len() > ps[best].Len() {
+			best = i
+		}
+	}
+	if best < 0 {
+		return nil, 0, false
+	}
+	return clonePrefix(&ps[best]), ps[best].Len(), true
+}
+
+// Canonical returns a list of distinct prefixes in ps in the
+// canonical order, which is suitable for stable serialization.
+// In the canonical order, IPv4 prefixes come before IPv6 prefixes,
+// and prefixes of the same address family are sorted by address and
+// then by length.
+func Canonical(ps []Prefix) []Prefix {
+	ps4 := newSortedPrefixes(byAddrFamily(ps).newIPv4Prefixes(), sortAscending, false)
+	ps6 := newSortedPrefixes(byAddrFamily(ps).newIPv6Prefixes(), sortAscending, false)
+	return append(ps4, ps6...)
+}
+
+// CommonPrefixAddrs returns the longest prefix that contains all the
+// IP addresses in ips.
+// It returns an error when ips is empty or ips contain addresses of
+// different address families.
+func CommonPrefixAddrs(ips []net.IP) (*Prefix, error) {
+	if len(ips) == 0 {
+		return nil, errors.New("no address")
+	}
+	if ips[0].To4() != nil {
+		base, n := ipToIPv4Int(ips[0]), IPv4PrefixLen
+		for _, ip := range ips[1:] {
+			if ip.To4() == nil {
+				return nil, errors.New("mixed address families")
+			}
+			if l := bits.LeadingZeros32(uint32(base ^ ipToIPv4Int(ip))); l < n {
+				n = l
+			}
+		}
+		return ipToPrefix(ips[0], n, IPv4PrefixLen), nil
+	}
+	if len(ips[0]) != net.IPv6len {
+		return nil, errors.New("invalid address")
+	}
+	base, n := ipToIPv6Int(ips[0]), IPv6PrefixLen
+	for _, ip := range ips[1:] {
+		if len(ip) != net.IPv6len || ip.To4() != nil {
+			return nil, errors.New("mixed address families")
+		}
+		i := ipToIPv6Int(ip)
+		l := bits.LeadingZeros64(base[0] ^ i[0])
+		if l == 64 {
+			l += bits.LeadingZeros64(base[1] ^ i[1])
+		}
+		if l < n {
+			n = l
+		}
+	}
+	return ipToPrefix(ips[0], n, IPv6PrefixLen), nil
+}
+
+// Compare returns an integer comparing two prefixes.
+// The result will be 0 if a == b, -1 if a < b, and +1 if a > b.
+func Compare(a, b *Prefix) int {
+	return compareAscending(a, b)
+}
+
+// Complement returns a list of prefixes that cover all the addresses
+// not covered by ps in the whole address space of the address family
+// afi, which is the IANA address family number as AFI returns.
+// It returns nil when ps cover the whole address space.
+func Complement(afi int, ps []Prefix) []Prefix {
+	var p *Prefix
+	switch afi {
+	case 1:
+		p = ipToPrefix(net.IPv4zero, 0, IPv4PrefixLen)
+	case 2:
+		p = ipToPrefix(net.IPv6unspecified, 0, IPv6PrefixLen)
+	default:
+		return nil
+	}
+	return p.gaps(ps)
+}
+
+// ContainmentDepth returns the number of prefixes in set that
+// contain p and are not equal to p.
+func ContainmentDepth(p *Prefix, set []Prefix) int {
+	n := 0
+	for i := range set {
+		if set[i].Contains(p) {
+			n++
+		}
+	}
+	return n
+}
+
+// CountBlocks returns the number of distinct address blocks of
+// prefix length l that ps touch.
+// It ignores prefixes that belong to an address family which has no
+// block of prefix length l.
+func CountBlocks(ps []Prefix, l int) *big.Int {
+	nps := make([]Prefix, 0, len(ps))
+	for i := range ps {
+		z := ps[i].bitLen()
+		if l < 0 || l > z {
+			continue
+		}
+		if ps[i].Len() > l {
+			nps = append(nps, *ipToPrefix(ps[i].IP, l, z))
+		} else {
+			nps = append(nps, ps[i])
+		}
+	}
+	n := new(big.Int)
+	for _, p := range newDisjointPrefixes(nps) {
+		n.Add(n, new(big.Int).Lsh(big.NewInt(1), uint(l-p.Len())))
+	}
+	return n
+}
+
+// CoveringPrefixesOf returns a list of all the prefixes that contain
+// ip, from the prefix of length 0 to the prefix of the full length of
+// the address family of ip, in order of increasing length.
+// It returns nil when ip is not a valid address.
+func CoveringPrefixesOf(ip net.IP) []Prefix {
+	if ip.To16() == nil {
+		return nil
+	}
+	z := IPv6PrefixLen
+	if ip.To4() != nil {
+		z = IPv4PrefixLen
+	}
+	ps := make([]Prefix, 0, z+1)
+	for l := 0; l <= z; l++ {
+		ps = append(ps, *ipToPrefix(ip, l, z))
+	}
+	return ps
+}
+
+// Density returns the fraction of the address space of parent that
+// is covered by children.
+// Overlapping children are counted once, and the portions of children
+// outside parent are ignored.
+func Density(parent *Prefix, children []Prefix) float64 {
+	f, _ := new(big.Rat).SetFrac(coveredNodes(parent, children), parent.NumNodes()).Float64()
+	return f
+}
+
+// coveredNodes returns the number of addresses in parent that are
+// covered by children.
+func coveredNodes(parent *Prefix, children []Prefix) *big.Int {
+	covered := new(big.Int)
+	for _, p := range newDisjointPrefixes(children) {
+		if p.Contains(parent) || p.Equal(parent) {
+			return parent.NumNodes()
+		}
+		if parent.Contains(&p) {
+			covered.Add(covered, p.NumNodes())
+		}
+	}
+	return covered
 }
 
-func subnetsIPv4(p *Prefix, reuse bool) (l *Prefix, r *Prefix) {
-	i := ipToIPv4Int(p.IP) | ipv4Int(1<<uint(IPv4PrefixLen-p.Len()-1))
-	r = i.prefix(p.Len()+1, IPv4PrefixLen)
-	if reuse {
-		l = p
-		binary.BigEndian.PutUint32(l.Mask, mask32(l.Len()+1))
-	} else {
-		l = ipToIPv4Int(p.IP).prefix(p.Len()+1, IPv4PrefixLen)
+// EqualMapped reports whether a and b are equal when an IPv4-mapped
+// IPv6 prefix is regarded as the corresponding IPv4 prefix.
+// An IPv6 prefix whose length is 96 or longer and whose address is in
+// ::ffff:0:0/96 maps to the IPv4 prefix of its length minus 96; for
+// example, ::ffff:192.0.2.0/120 and 192.0.2.0/24 are equal.
+// Any other IPv6 prefix is never equal to an IPv4 prefix.
+func EqualMapped(a, b *Prefix) bool {
+	ipv4a, la := a.mappedLen()
+	ipv4b, lb := b.mappedLen()
+	return ipv4a == ipv4b && la == lb && a.IP.Equal(b.IP)
+}
+
+// mappedLen reports whether p is an IPv4 or IPv4-mapped IPv6 prefix,
+// and returns the length of p in the address family.
+func (p *Prefix) mappedLen() (ipv4 bool, l int) {
+	l = p.Len()
+	if len(p.Mask) == net.IPv4len {
+		return true, l
 	}
-	return
+	if p.IP.To4() != nil && l >= IPv6PrefixLen-IPv4PrefixLen {
+		return true, l - (IPv6PrefixLen - IPv4PrefixLen)
+	}
+	return false, l
 }
 
-func subnetsIPv6(p *Prefix, reuse bool) (l *Prefix, r *Prefix) {
-	i := ipToIPv6Int(p.IP)
-	id := ipv6Int{0, 1}
-	id.lsh(IPv6PrefixLen - p.Len() - 1)
-	ii := ipv6Int{i[0] | id[0], i[1] | id[1]}
-	r = ii.prefix(p.Len()+1, IPv6PrefixLen)
-	if reuse {
-		l = p
-		var m ipv6Int
-		m.mask(l.Len() + 1)
-		binary.BigEndian.PutUint64(l.Mask[:8], m[0])
-		binary.BigEndian.PutUint64(l.Mask[8:16], m[1])
-	} else {
-		l = i.prefix(p.Len()+1, IPv6PrefixLen)
+// FindUnaligned returns the indices of entries in raw whose IP has
+// bits set beyond Len, which NewPrefix would silently clear.
+// It also returns the indices of entries whose IP is not a valid
+// address or whose Len is out of range for the address family of IP.
+func FindUnaligned(raw []struct {
+	IP  net.IP
+	Len int
+}) []int {
+	var idxs []int
+	for i, r := range raw {
+		z := IPv6PrefixLen
+		if r.IP.To4() != nil {
+			z = IPv4PrefixLen
+		}
+		if r.IP.To16() == nil || r.Len < 0 || r.Len > z {
+			idxs = append(idxs, i)
+			continue
+		}
+		if p := ipToPrefix(r.IP, r.Len, z); !p.IP.Equal(r.IP) {
+			idxs = append(idxs, i)
+		}
 	}
-	return
+	return idxs
 }
 
-// Hostmask returns a host mask, the inverse mask of p's network mask.
-func (p *Prefix) Hostmask() net.IPMask {
-	return invert(p.Mask)
+// Generations returns the number of levels that separate descendant
+// from ancestor, which is the length of descendant minus the length
+// of ancestor.
+// It returns false when ancestor neither contains nor equals
+// descendant.
+func Generations(ancestor, descendant *Prefix) (int, bool) {
+	if !ancestor.Equal(descendant) && !ancestor.Contains(descendant) {
+		return 0, false
+	}
+	return descendant.Len() - ancestor.Len(), true
 }
 
-// Last returns the last IP in the address range of p.
-// It returns the address of p when p contains only one address.
-func (p *Prefix) Last() net.IP {
-	if p.IP.To4() != nil {
-		i := p.lastIPv4Int()
-		return i.ip()
+// HammingDistance returns the number of bits that differ between the
+// addresses of a and b.
+// It returns an error when a and b belong to different address
+// families.
+func HammingDistance(a, b *Prefix) (int, error) {
+	if (a.IP.To4() != nil) != (b.IP.To4() != nil) {
+		return 0, errors.New("address family mismatch")
 	}
-	if p.IP.To16() != nil && p.IP.To4() == nil {
-		i := p.lastIPv6Int()
-		return i.ip()
+	x, y := ipToIPv6Int(a.IP.To16()), ipToIPv6Int(b.IP.To16())
+	return bits.OnesCount64(x[0]^y[0]) + bits.OnesCount64(x[1]^y[1]), nil
+}
+
+// InsertNonOverlapping returns a list of prefixes that consists of set
+// and p.
+// It returns an error when p overlaps with any prefix in set.
+func InsertNonOverlapping(set []Prefix, p *Prefix) ([]Prefix, error) {
+	for i := range set {
+		if p.Overlaps(&set[i]) {
+			return nil, errors.New("overlapping prefix")
+		}
 	}
-	return nil
+	ps := make([]Prefix, len(set), len(set)+1)
+	copy(ps, set)
+	return append(ps, *p), nil
 }
 
-// Len returns the length of p in bits.
-func (p *Prefix) Len() int {
-	l, _ := p.Mask.Size()
-	return l
+// InsertSplit returns a list of prefixes that consists of set and the
+// subnetworks of p which do not overlap with any prefix in set.
+func InsertSplit(set []Prefix, p *Prefix) []Prefix {
+	gaps := p.gaps(set)
+	ps := make([]Prefix, len(set), len(set)+len(gaps))
+	copy(ps, set)
+	return append(ps, gaps...)
 }
 
-// MarshalBinary returns a BGP NLRI binary form of p.
-func (p *Prefix) MarshalBinary() ([]byte, error) {
-	ip := p.IP
-	if p.IP.To4() != nil {
-		ip = p.IP.To4()
+// Intersect returns the prefix that consists of the addresses in both
+// a and b, which is the longer of a and b when one contains the other.
+// It returns nil when a and b do not overlap or belong to different
+// address families.
+func Intersect(a, b *Prefix) *Prefix {
+	switch {
+	case a.ContainsPrefix(b):
+		return clonePrefix(b)
+	case b.Contains(a):
+		return clonePrefix(a)
 	}
-	var b [1 + net.IPv6len]byte
-	n := p.Len()
-	l := ((n + 8 - 1) &^ (8 - 1)) / 8
-	b[0] = byte(n)
-	l++
-	copy(b[1:l], ip)
-	return b[:l], nil
+	return nil
 }
 
-// MarshalText returns a UTF-8-encoded text form of p.
-func (p *Prefix) MarshalText() ([]byte, error) {
-	return []byte(p.String()), nil
+// IsAggregated reports whether ps is already aggregated, that is,
+// Aggregate would neither merge nor remove any prefix in ps.
+func IsAggregated(ps []Prefix) bool {
+	ipv4, ipv6 := AggregateSafe(ps)
+	return isAggregatedFamily(byAddrFamily(ps).newIPv4Prefixes(), ipv4) && isAggregatedFamily(byAddrFamily(ps).newIPv6Prefixes(), ipv6)
 }
 
-// NumNodes returns the number of IP node addresses in p.
-func (p *Prefix) NumNodes() *big.Int {
-	i := new(big.Int).SetBytes(invert(p.Mask))
-	return i.Add(i, big.NewInt(1))
+// isAggregatedFamily reports whether ps, a list of prefixes of the
+// same address family, equals agg, the aggregated list of ps.
+func isAggregatedFamily(ps, agg []Prefix) bool {
+	if len(agg) != len(ps) {
+		return false
+	}
+	sortByAscending(ps)
+	for i := range ps {
+		if !ps[i].Equal(&agg[i]) {
+			return false
+		}
+	}
+	return true
 }
 
-// Overlaps reports whether p overlaps with q.
-func (p *Prefix) Overlaps(q *Prefix) bool {
-	return p.Contains(q) || q.Contains(p) || p.Equal(q)
+// IsSubnetOf reports whether child is a proper subnetwork of parent.
+// It returns false when child equals parent or they belong to
+// different address families.
+func IsSubnetOf(child, parent *Prefix) bool {
+	return child.Len() > parent.Len() && parent.Contains(child)
 }
 
-func (p Prefix) String() string {
-	return p.IPNet.String()
+// IsSubset reports whether all the addresses covered by a are also
+// covered by b.
+func IsSubset(a, b []Prefix) bool {
+	for i := range a {
+		if len(a[i].gaps(b)) > 0 {
+			return false
+		}
+	}
+	return true
 }
 
-// Subnets returns a list of prefixes that are split from p, into
-// small address blocks by n which represents a number of subnetworks
-// in the power of 2 notation.
-func (p *Prefix) Subnets(n int) []Prefix {
-	if 0 > n || n > 17 { // don't bother runtime.makeslice by big numbers
+// LargestExcluding returns the shortest prefix that contains include
+// but does not contain exclude.
+// The length of the prefix is one plus the number of leading bits
+// that include and exclude have in common.
+// It returns nil when include and exclude are the same address or
+// belong to different address families.
+func LargestExcluding(include, exclude net.IP) *Prefix {
+	if include.To16() == nil || exclude.To16() == nil || (include.To4() != nil) != (exclude.To4() != nil) {
 		return nil
 	}
-	ps := make([]Prefix, 1<<uint(n))
-	if p.IP.To4() != nil {
-		x := ipToIPv4Int(p.IP)
-		off := uint(IPv4PrefixLen - p.Len() - n)
-		for i := range ps {
-			ii := x | ipv4Int(i<<off)
-			ps[i] = *ii.prefix(p.Len()+n, IPv4PrefixLen)
-		}
-		return ps
+	z := IPv6PrefixLen
+	if include.To4() != nil {
+		z = IPv4PrefixLen
 	}
-	x := ipToIPv6Int(p.IP)
-	off := IPv6PrefixLen - p.Len() - n
-	for i := range ps {
-		id := ipv6Int{0, uint64(i)}
-		id.lsh(off)
-		ii := ipv6Int{x[0] | id[0], x[1] | id[1]}
-		ps[i] = *ii.prefix(p.Len()+n, IPv6PrefixLen)
+	a, b := ipToIPv6Int(include.To16()), ipToIPv6Int(exclude.To16())
+	if a.cmp(&b) == 0 {
+		return nil
 	}
-	return ps
+	n := bits.LeadingZeros64(a[0] ^ b[0])
+	if n == 64 {
+		n += bits.LeadingZeros64(a[1] ^ b[1])
+	}
+	return ipToPrefix(include, n+1-(IPv6PrefixLen-z), z)
 }
 
-// UnmarshalBinary replaces p with the BGP NLRI binary form b.
-func (p *Prefix) UnmarshalBinary(b []byte) error {
-	if p.IP.To4() != nil {
-		binary.BigEndian.PutUint32(p.Mask, mask32(int(b[0])))
-		copy(p.IP, net.IPv4zero)
-		copy(p.IP.To4(), b[1:])
+// MergeBenefit returns the number of prefixes saved by merging a and
+// b and aggregating the result, which is the number of prefixes in a
+// and b minus the number of aggregated prefixes.
+func MergeBenefit(a, b []Prefix) int {
+	ps := make([]Prefix, 0, len(a)+len(b))
+	ps = append(append(ps, a...), b...)
+	return len(ps) - len(Aggregate(ps))
+}
+
+// MergePair returns the prefix that consists of a and b when a and b
+// are sibling prefixes of the same length.
+// It returns false when a and b are not mergeable.
+func MergePair(a, b *Prefix) (*Prefix, bool) {
+	l, z := a.Len(), a.bitLen()
+	if l == 0 || l != b.Len() || z != b.bitLen() || a.Equal(b) {
+		return nil, false
 	}
-	if p.IP.To16() != nil && p.IP.To4() == nil {
-		var m ipv6Int
-		m.mask(int(b[0]))
-		binary.BigEndian.PutUint64(p.Mask[:8], m[0])
-		binary.BigEndian.PutUint64(p.Mask[8:16], m[1])
-		copy(p.IP, net.IPv6unspecified)
-		copy(p.IP, b[1:])
+	p := ipToPrefix(a.IP, l-1, z)
+	if !p.IPNet.Contains(b.IP) {
+		return nil, false
 	}
-	return nil
+	return p, true
 }
 
-// UnmarshalText replaces p with txt.
-func (p *Prefix) UnmarshalText(txt []byte) error {
-	_, n, err := net.ParseCIDR(string(txt))
-	if err != nil {
-		return err
-	}
-	copy(p.IP.To16(), n.IP.To16())
-	copy(p.Mask, n.Mask)
-	return nil
+// NewPrefix returns a new prefix.
+func NewPrefix(n *net.IPNet) *Prefix {
+	n.IP = n.IP.To16()
+	return &Prefix{IPNet: *n}
 }
 
-// Aggregate aggregates ps and returns a list of aggregated prefixes.
-func Aggregate(ps []Prefix) []Prefix {
-	ps = newSortedPrefixes(ps, sortAscending, true)
-	sortByDescending(ps)
-	switch len(ps) {
-	case 0:
+// NextPrefixAfter returns the first prefix in ascending order of ps
+// whose first address is equal to or greater than ip.
+// It returns nil when no such prefix of the same address family as ip
+// exists.
+func NextPrefixAfter(ip net.IP, ps []Prefix) *Prefix {
+	if ip.To16() == nil {
 		return nil
-	case 1:
-		return ps[:1]
 	}
-	bfFn, superFn := branchingFactorIPv6, supernetIPv6
-	if ps[0].IP.To4() != nil {
-		bfFn, superFn = branchingFactorIPv4, supernetIPv4
+	ip4 := ip.To4() != nil
+	var nps []Prefix
+	for i := range ps {
+		if (ps[i].IP.To4() != nil) == ip4 {
+			nps = append(nps, ps[i])
+		}
 	}
-	ps = aggregate(aggregateByBF(ps, bfFn, superFn))
-	sortByAscending(ps)
-	return ps
+	nps = newSortedPrefixes(nps, sortAscending, false)
+	ip = ip.To16()
+	i := sort.Search(len(nps), func(i int) bool {
+		return bytes.Compare(nps[i].IP, ip) >= 0
+	})
+	if i == len(nps) {
+		return nil
+	}
+	return &nps[i]
 }
 
-func aggregateByBF(ps []Prefix, bfFn func([]Prefix) (int, bool), superFn func([]Prefix) *Prefix) []Prefix {
-	var cont bool
-	aggrs := ps[:0]
-	cands := make([]Prefix, 0, len(ps))
-	for len(ps) > 0 {
-		l := ps[0].Len()
-		if l == 0 {
-			aggrs = append(aggrs, ps[0])
-			ps = ps[1:]
-			continue
-		}
-		cands = cands[:0]
-		for i := range ps {
-			if ps[i].Len() != l {
-				break
-			}
-			cands = append(cands, ps[i])
-		}
-		if n, ok := bfFn(cands); !ok {
-			aggrs = append(aggrs, ps[0])
-			ps = ps[1:]
-		} else {
-			aggrs = append(aggrs, *superFn(cands[:n]))
-			ps = ps[n:]
-			cont = true
-		}
-	}
-	sortByDescending(aggrs)
-	if !cont {
-		return aggrs
+// Overlaps reports whether a and b overlap.
+// It returns false when a and b belong to different address families
+// or either of them is nil.
+func Overlaps(a, b *Prefix) bool {
+	if a == nil || b == nil || (a.IP.To4() != nil) != (b.IP.To4() != nil) {
+		return false
 	}
-	return aggregateByBF(aggrs, bfFn, superFn)
+	return a.Overlaps(b)
 }
 
-func aggregate(ps []Prefix) []Prefix {
-	aggrs := ps[:0]
+// Parents returns a list of indices of the immediate parent prefixes
+// of ps.
+// The i'th element of the returned list is the index of the longest
+// prefix in ps that contains ps[i], or -1 when no prefix in ps
+// contains ps[i].
+func Parents(ps []Prefix) []int {
+	parents := make([]int, len(ps))
+	var idx4, idx6 []int
 	for i := range ps {
-		var aggregatable bool
-		for j := len(ps) - 1; j >= 0; j-- {
-			if i == j {
+		parents[i] = -1
+		if ps[i].IP.To4() != nil {
+			idx4 = append(idx4, i)
+		} else {
+			idx6 = append(idx6, i)
+		}
+	}
+	for _, idx := range [][]int{idx4, idx6} {
+		sort.SliceStable(idx, func(i, j int) bool {
+			return compareAscending(&ps[idx[i]], &ps[idx[j]]) < 0
+		})
+		var stk []int // indices of the ancestors of the current prefix
+		for _, i := range idx {
+			for len(stk) > 0 {
+				top := stk[len(stk)-1]
+				if ps[top].Equal(&ps[i]) || ps[top].Contains(&ps[i]) {
+					break
+				}
+				stk = stk[:len(stk)-1]
+			}
+			if len(stk) == 0 {
+				stk = append(stk, i)
 				continue
 			}
-			if ps[j].Contains(&ps[i]) {
-				aggregatable = true
-				break
+			if top := stk[len(stk)-1]; ps[top].Equal(&ps[i]) {
+				parents[i] = parents[top]
+			} else {
+				parents[i] = top
+				stk = append(stk, i)
 			}
 		}
-		if aggregatable {
-			continue
-		}
-		aggrs = append(aggrs, ps[i])
 	}
-	return aggrs
+	return parents
 }
 
-func branchingFactorIPv4(ps []Prefix) (int, bool) {
-	var lastBF, lastN int
-	base := ipToIPv4Int(ps[0].IP.Mask(ps[0].Mask))
-	mask := ipMaskToIPv4Int(ps[0].Mask)
-	l := ps[0].Len()
-	for bf := 1; bf < IPv4PrefixLen; bf++ {
-		n, nfull := 0, 1<<uint(bf)
-		max := ipv4Int(1 << uint(bf))
-		aggrMask := mask << uint(bf)
-		for pat := ipv4Int(0); pat < max; pat++ {
-			aggr := base&aggrMask | pat<<uint(IPv4PrefixLen-l)
-			for _, p := range ps {
-				i := ipToIPv4Int(p.IP)
-				if aggr == i&mask {
-					n++
-				}
-			}
+// PrefixFromBounds returns the prefix whose address range is exactly
+// from first to last.
+// It returns false when the addresses from first to last do not form
+// a single aligned address block.
+func PrefixFromBounds(first, last net.IP) (*Prefix, bool) {
+	if first.To16() == nil || last.To16() == nil || (first.To4() != nil) != (last.To4() != nil) {
+		return nil, false
+	}
+	z := IPv6PrefixLen
+	if first.To4() != nil {
+		z = IPv4PrefixLen
+	}
+	f, l := ipToIPv6Int(first.To16()), ipToIPv6Int(last.To16())
+	n := bits.LeadingZeros64(f[0] ^ l[0])
+	if n == 64 {
+		n += bits.LeadingZeros64(f[1] ^ l[1])
+	}
+	var m ipv6Int
+	m.invmask(n)
+	if f[0]&m[0] != 0 || f[1]&m[1] != 0 || l[0]&m[0] != m[0] || l[1]&m[1] != m[1] {
+		return nil, false
+	}
+	return ipToPrefix(first, n-(IPv6PrefixLen-z), z), true
+}
+
+// PrefixesFromBitmap returns a list of prefixes that cover exactly
+// the addresses in base that are marked in bitmap, in ascending
+// order.
+// The i'th bit of bitmap, counting from the most significant bit of
+// the first byte, marks the address at the offset i from the network
+// address of base.
+// It ignores bits beyond the address range of base, and returns nil
+// when base contains more than 2^24 addresses.
+func PrefixesFromBitmap(base *Prefix, bitmap []byte) []Prefix {
+	if base.hostLen() > 24 {
+		return nil
+	}
+	n := len(bitmap) * 8
+	if m := 1 << uint(base.hostLen()); n > m {
+		n = m
+	}
+	marked := func(i int) bool { return bitmap[i/8]&(0x80>>uint(i%8)) != 0 }
+	addr := func(i int) net.IP {
+		ii := ipToInt(base.IP)
+		return intToIP(ii.Add(ii, big.NewInt(int64(i))), base.bitLen())
+	}
+	var ps []Prefix
+	for i := 0; i < n; i++ {
+		if !marked(i) {
+			continue
 		}
-		if n < nfull {
-			break
+		j := i
+		for j+1 < n && marked(j+1) {
+			j++
 		}
-		lastBF = bf
-		lastN = n
+		ps = append(ps, Summarize(addr(i), addr(j))...)
+		i = j
 	}
-	n := 1 << uint(lastBF)
-	return n, lastN >= n
+	return ps
 }
 
-func branchingFactorIPv6(ps []Prefix) (int, bool) {
-	var lastBF, lastN int
-	base := ipToIPv6Int(ps[0].IP)
-	mask := ipMaskToIPv6Int(ps[0].Mask)
-	l := ps[0].Len()
-	for bf := 1; bf < IPv6PrefixLen; bf++ {
-		n, nfull := 0, 1<<uint(bf)
-		pat, max := ipv6Int{0, 0}, ipv6Int{0, 1}
-		max.lsh(bf)
-		var aggrMask ipv6Int
-		aggrMask.mask(l - bf)
-		for ; pat.cmp(&max) < 0; pat.incr() {
-			npat := pat
-			npat.lsh(IPv6PrefixLen - l)
-			var aggr ipv6Int
-			aggr[0], aggr[1] = base[0]&aggrMask[0]|npat[0], base[1]&aggrMask[1]|npat[1]
-			for _, p := range ps {
-				i := ipToIPv6Int(p.IP)
-				if aggr[0] == i[0]&mask[0] && aggr[1] == i[1]&mask[1] {
-					n++
-				}
-			}
+// PresentLengths returns the lists of distinct prefix lengths of the
+// IPv4 and IPv6 prefixes in ps, in ascending order.
+// A list is nil when ps contains no prefix of the address family.
+func PresentLengths(ps []Prefix) (ipv4, ipv6 []int) {
+	var ls4, ls6 [IPv6PrefixLen + 1]bool
+	for i := range ps {
+		if ps[i].IP.To4() != nil {
+			ls4[ps[i].Len()] = true
+		} else {
+			ls6[ps[i].Len()] = true
 		}
-		if n < nfull {
-			break
+	}
+	for l := 0; l <= IPv6PrefixLen; l++ {
+		if ls4[l] {
+			ipv4 = append(ipv4, l)
+		}
+		if ls6[l] {
+			ipv6 = append(ipv6, l)
 		}
-		lastBF = bf
-		lastN = n
 	}
-	n := 1 << uint(lastBF)
-	return n, lastN >= n
+	return ipv4, ipv6
 }
 
-// Compare returns an integer comparing two prefixes.
-// The result will be 0 if a == b, -1 if a < b, and +1 if a > b.
-func Compare(a, b *Prefix) int {
-	return compareAscending(a, b)
+// ReverseZones returns a list of reverse DNS zone names, such as
+// "2.0.192.in-addr.arpa" and "8.b.d.0.1.0.0.2.ip6.arpa", that are
+// required to delegate the address space of ps.
+// An IPv4 zone is delegated at an octet boundary and an IPv6 zone is
+// delegated at a nibble boundary; a prefix not aligned with a
+// boundary is split into zones at the next longer boundary, and a
+// prefix longer than /24 for IPv4 or /124 for IPv6 belongs to the
+// zone that contains it.
+// The list consists of IPv4 zones followed by IPv6 zones, in
+// ascending order of addresses, and contains no zone that is covered
+// by another zone in the list.
+func ReverseZones(ps []Prefix) []string {
+	var ps4, ps6 []Prefix
+	for i := range ps {
+		b := 8
+		if ps[i].IP.To4() == nil {
+			b = 4
+		}
+		var zps []Prefix
+		if l := ps[i].bitLen() - b; ps[i].Len() > l {
+			zps = []Prefix{*ipToPrefix(ps[i].IP, l, ps[i].bitLen())}
+		} else {
+			zps = ps[i].ContainedBlocks(ps[i].SnapLen(b, true).Len())
+		}
+		if b == 8 {
+			ps4 = append(ps4, zps...)
+		} else {
+			ps6 = append(ps6, zps...)
+		}
+	}
+	var zones []string
+	for _, p := range append(newDisjointPrefixes(ps4), newDisjointPrefixes(ps6)...) {
+		zones = append(zones, reverseZone(&p))
+	}
+	return zones
 }
 
-// NewPrefix returns a new prefix.
-func NewPrefix(n *net.IPNet) *Prefix {
-	n.IP = n.IP.To16()
-	return &Prefix{IPNet: *n}
+// reverseZone returns the reverse DNS zone name for p.
+// The length of p must be a multiple of 8 for IPv4 or 4 for IPv6.
+func reverseZone(p *Prefix) string {
+	var b []byte
+	if ip := p.IP.To4(); ip != nil {
+		for i := p.Len()/8 - 1; i >= 0; i-- {
+			b = strconv.AppendUint(b, uint64(ip[i]), 10)
+			b = append(b, '.')
+		}
+		return string(append(b, "in-addr.arpa"...))
+	}
+	for i := p.Len()/4 - 1; i >= 0; i-- {
+		n := p.IP[i/2] >> 4
+		if i%2 == 1 {
+			n = p.IP[i/2] & 0x0f
+		}
+		b = append(b, "0123456789abcdef"[n], '.')
+	}
+	return string(append(b, "ip6.arpa"...))
 }
 
 // Summarize summarizes the address range from first to last and
@@ -526,13 +2113,17 @@ func Supernet(ps []Prefix) *Prefix {
 	case 1:
 		return &ps[0]
 	}
+	var super *Prefix
 	if ps[0].IP.To4() != nil {
-		return supernetIPv4(ps)
+		super = supernetIPv4(ps)
 	}
 	if ps[0].IP.To16() != nil && ps[0].IP.To4() == nil {
-		return supernetIPv6(ps)
+		super = supernetIPv6(ps)
 	}
-	return nil
+	if Debug && super != nil {
+		checkSupernet(ps, super)
+	}
+	return super
 }
 
 func supernetIPv4(ps []Prefix) *Prefix {
@@ -577,6 +2168,120 @@ func supernetIPv6(ps []Prefix) *Prefix {
 	return ipToPrefix(ps[0].IP, n, IPv6PrefixLen)
 }
 
+// Timeline returns the address space of container partitioned into
+// the prefixes allocated by allocated and the free prefixes in
+// ascending order.
+// The portions of allocated outside container are ignored.
+func Timeline(container *Prefix, allocated []Prefix) []TimelineSegment {
+	for i := range allocated {
+		if allocated[i].Equal(container) || allocated[i].Contains(container) {
+			return []TimelineSegment{{Prefix: *ipToPrefix(container.IP, container.Len(), container.bitLen())}}
+		}
+	}
+	var nps []Prefix
+	for i := range allocated {
+		if container.Contains(&allocated[i]) {
+			nps = append(nps, allocated[i])
+		}
+	}
+	var segs []TimelineSegment
+	for _, p := range newDisjointPrefixes(nps) {
+		segs = append(segs, TimelineSegment{Prefix: p})
+	}
+	for _, p := range container.gaps(nps) {
+		segs = append(segs, TimelineSegment{Prefix: p, Free: true})
+	}
+	sort.Slice(segs, func(i, j int) bool {
+		return compareAscending(&segs[i].Prefix, &segs[j].Prefix) < 0
+	})
+	return segs
+}
+
+// VLSM allocates a list of prefixes which can accommodate the number
+// of hosts listed in nhosts from parent, in descending order of the
+// number of hosts.
+// The returned list is in the same order as nhosts.
+func VLSM(parent *Prefix, nhosts []int) ([]Prefix, error) {
+	z := parent.bitLen()
+	ls := make([]int, len(nhosts))
+	for i, n := range nhosts {
+		if n <= 0 {
+			return nil, errors.New("invalid number of hosts")
+		}
+		l := prefixLenForHosts(n, z)
+		if l < parent.Len() {
+			return nil, errors.New("too many hosts")
+		}
+		ls[i] = l
+	}
+	idx := make([]int, len(nhosts))
+	for i := range idx {
+		idx[i] = i
+	}
+	sort.SliceStable(idx, func(i, j int) bool { return ls[idx[i]] < ls[idx[j]] })
+	ps := make([]Prefix, len(nhosts))
+	base, off, max := ipToInt(parent.IP), new(big.Int), parent.NumNodes()
+	for _, i := range idx {
+		size := new(big.Int).Lsh(big.NewInt(1), uint(z-ls[i]))
+		if new(big.Int).Add(off, size).Cmp(max) > 0 {
+			return nil, errors.New("insufficient address space")
+		}
+		ip := intToIP(new(big.Int).Add(base, off), z)
+		ps[i] = *ipToPrefix(ip, ls[i], z)
+		off.Add(off, size)
+	}
+	return ps, nil
+}
+
+// prefixLenForHosts returns the longest prefix length in the address
+// family of which the maximum prefix length is z, that can accommodate
+// n host-assignable addresses.
+// It returns -1 when no suitable prefix length is found.
+func prefixLenForHosts(n, z int) int {
+	if n <= 0 {
+		return -1
+	}
+	for l := z; l >= 0; l-- {
+		h := z - l
+		if h >= 63 {
+			return l
+		}
+		m := 1 << uint(h)
+		switch {
+		case z == IPv4PrefixLen && h > 1:
+			m -= 2 // network and directed broadcast addresses
+		case z == IPv6PrefixLen && h > 0:
+			m-- // subnet-router anycast address
+		}
+		if m >= n {
+			return l
+		}
+	}
+	return -1
+}
+
+// WalkHierarchy calls fn for each prefix in ps in ascending order with
+// its nesting depth.
+// The depth of a prefix that is not contained by any other prefix in
+// ps is 0.
+func WalkHierarchy(ps []Prefix, fn func(p *Prefix, depth int)) {
+	parents := Parents(ps)
+	idx := make([]int, len(ps))
+	for i := range idx {
+		idx[i] = i
+	}
+	sort.SliceStable(idx, func(i, j int) bool {
+		return compareAscending(&ps[idx[i]], &ps[idx[j]]) < 0
+	})
+	depths := make([]int, len(ps))
+	for _, i := range idx {
+		if parents[i] >= 0 {
+			depths[i] = depths[parents[i]] + 1
+		}
+		fn(&ps[i], depths[i])
+	}
+}
+
 type ipv4Int uint32
 
 func (i ipv4Int) cmp(j ipv4Int) int {
@@ -675,6 +2380,24 @@ func (i *ipv6Int) prefix(l, z int) *Prefix {
 	return &Prefix{IPNet: net.IPNet{IP: ip.Mask(m), Mask: m}}
 }
 
+func ipToInt(ip net.IP) *big.Int {
+	if ip4 := ip.To4(); ip4 != nil {
+		return new(big.Int).SetBytes(ip4)
+	}
+	return new(big.Int).SetBytes(ip.To16())
+}
+
+func intToIP(i *big.Int, z int) net.IP {
+	if z == IPv4PrefixLen {
+		var b [net.IPv4len]byte
+		i.FillBytes(b[:])
+		return net.IPv4(b[0], b[1], b[2], b[3])
+	}
+	ip := make(net.IP, net.IPv6len)
+	i.FillBytes(ip)
+	return ip
+}
+
 func invert(s []byte) []byte {
 	d := make([]byte, len(s))
 	for i := range s {
@@ -6,11 +6,16 @@ package ipaddr_test
 
 import (
 	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
 	"math/big"
 	"net"
 	"reflect"
 	"sort"
+	"strconv"
 	"testing"
+	"testing/iotest"
 
 	"github.com/mikioh/ipaddr"
 )
@@ -57,6 +62,32 @@ func toPrefixes(ss []string) []ipaddr.Prefix {
 	return ps
 }
 
+func TestAdjacencyEdges(t *testing.T) {
+	for i, tt := range []struct {
+		in   []string
+		want [][2]int
+	}{
+		{
+			[]string{"10.1.2.0/24", "10.0.0.0/8", "10.1.0.0/16"},
+			[][2]int{{2, 0}, {1, 2}},
+		},
+		{
+			[]string{"10.1.2.0/24", "10.0.0.0/8", "192.0.2.0/24", "10.1.0.0/16", "10.1.3.0/24", "10.2.0.0/16"},
+			[][2]int{{3, 0}, {1, 3}, {3, 4}, {1, 5}},
+		},
+		{
+			[]string{"::/0", "10.0.0.0/8", "2001:db8::/32"},
+			[][2]int{{0, 2}},
+		},
+		{[]string{"10.0.0.0/8", "192.0.2.0/24"}, nil},
+		{nil, nil},
+	} {
+		if out := ipaddr.AdjacencyEdges(toPrefixes(tt.in)); !reflect.DeepEqual(out, tt.want) {
+			t.Errorf("#%d: got %v; want %v", i, out, tt.want)
+		}
+	}
+}
+
 func TestAggregate(t *testing.T) {
 	for i, tt := range []struct {
 		in, want []string
@@ -218,6 +249,56 @@ func TestAggregate(t *testing.T) {
 				"192.0.2.0/24", "198.51.100.0/25",
 			},
 		},
+		// overlapping prefixes for which a supernet of the largest
+		// ones covers addresses not in the input
+		{
+			[]string{
+				"10.0.0.0/22", "10.0.8.0/22", "10.0.10.0/23", "10.0.8.0/23",
+				"10.0.0.0/21", "10.0.9.128/25",
+			},
+			[]string{
+				"10.0.0.0/21", "10.0.8.0/22",
+			},
+		},
+		// unsorted prefixes of various lengths; not a regression
+		// case, the former implementation aggregates them as well
+		{
+			[]string{
+				"10.1.0.0/21", "10.1.8.0/22", "10.1.12.0/24", "10.1.14.0/24",
+				"10.1.4.0/22", "10.1.13.0/24", "10.1.16.0/24", "10.1.24.0/21",
+				"10.1.8.0/24", "10.1.17.0/24", "10.1.32.0/22", "10.1.40.0/21",
+				"10.1.15.0/24",
+			},
+			[]string{
+				"10.1.0.0/20", "10.1.16.0/23", "10.1.24.0/21", "10.1.32.0/22",
+				"10.1.40.0/21",
+			},
+		},
+		{
+			[]string{
+				"10.0.0.0/8", "10.1.0.0/16", "10.1.2.0/24",
+			},
+			[]string{
+				"10.0.0.0/8",
+			},
+		},
+		{
+			[]string{
+				"10.1.2.0/24", "10.1.0.0/16", "10.1.2.128/25", "10.2.0.0/16",
+			},
+			[]string{
+				"10.1.0.0/16", "10.2.0.0/16",
+			},
+		},
+		// halves of the entire address space
+		{
+			[]string{
+				"0.0.0.0/1", "128.0.0.0/1",
+			},
+			[]string{
+				"0.0.0.0/0",
+			},
+		},
 
 		// IPv6 prefixes
 		{
@@ -251,6 +332,14 @@ func TestAggregate(t *testing.T) {
 				"::/0",
 			},
 		},
+		{
+			[]string{
+				"::/1", "8000::/1",
+			},
+			[]string{
+				"::/0",
+			},
+		},
 	} {
 		in, orig, want := toPrefixes(tt.in), toPrefixes(tt.in), toPrefixes(tt.want)
 		sort.Sort(byAscending(want))
@@ -263,237 +352,1320 @@ func TestAggregate(t *testing.T) {
 		}
 	}
 
-	ipaddr.Aggregate(nil)
-}
-
-func TestCompare(t *testing.T) {
-	for i, tt := range []struct {
-		in []ipaddr.Prefix
-		n  int
-	}{
-		{toPrefixes([]string{"192.0.2.0/23", "192.0.2.0/24"}), -1},
-		{toPrefixes([]string{"192.0.2.0/24", "192.0.2.0/24"}), 0},
-		{toPrefixes([]string{"192.0.2.0/25", "192.0.2.0/24"}), +1},
-
-		{toPrefixes([]string{"192.0.2.0/24", "198.51.100.0/24"}), -1},
-		{toPrefixes([]string{"198.51.100.0/24", "198.51.100.0/24"}), 0},
-		{toPrefixes([]string{"203.0.113.0/24", "198.51.100.0/24"}), +1},
-
-		{toPrefixes([]string{"2001:db8:1::/47", "2001:db8:1::/48"}), -1},
-		{toPrefixes([]string{"2001:db8:1::/48", "2001:db8:1::/48"}), 0},
-		{toPrefixes([]string{"2001:db8:1::/49", "2001:db8:1::/48"}), +1},
-
-		{toPrefixes([]string{"2001:db8:1::/128", "2001:db8:1::1/128"}), -1},
-		{toPrefixes([]string{"2001:db8:1::1/128", "2001:db8:1::1/128"}), 0},
-		{toPrefixes([]string{"2001:db8:1::2/128", "2001:db8:1::1/128"}), +1},
-
-		{toPrefixes([]string{"192.0.2.1/24", "2001:db8:1::1/64"}), -1},
-		{toPrefixes([]string{"2001:db8:1::1/64", "192.0.2.1/24"}), +1},
+	for i, in := range [][]string{
+		{"2001:db8::/33", "10.0.0.0/9", "2001:db8:8000::/33", "10.128.0.0/9"},
+		{"10.0.0.0/9", "2001:db8::/33", "10.128.0.0/9", "2001:db8:8000::/33"},
 	} {
-		if n := ipaddr.Compare(&tt.in[0], &tt.in[1]); n != tt.n {
-			t.Errorf("#%d: got %v for %v; want %v", i, n, tt.in, tt.n)
+		want := toPrefixes([]string{"10.0.0.0/8", "2001:db8::/32"})
+		if out := ipaddr.Aggregate(toPrefixes(in)); !reflect.DeepEqual(out, want) {
+			t.Errorf("#%d: got %v; want %v", i, out, want)
 		}
 	}
+
+	ipaddr.Aggregate(nil)
 }
 
-func TestSummarize(t *testing.T) {
+func TestAggregatableGroups(t *testing.T) {
 	for i, tt := range []struct {
-		first, last string
-		want        []string
+		in   []string
+		want [][]string
 	}{
-		// IPv4 prefixes
 		{
-			"192.0.2.0", "192.0.2.255",
-			[]string{
-				"192.0.2.0/24",
+			[]string{"192.0.2.0/24", "192.0.3.0/24", "192.0.0.0/24", "192.0.1.0/24", "198.51.100.0/24"},
+			[][]string{
+				{"192.0.0.0/24", "192.0.1.0/24", "192.0.2.0/24", "192.0.3.0/24"},
+				{"198.51.100.0/24"},
 			},
 		},
 		{
-			"1.2.3.4", "5.6.7.8",
-			[]string{
-				"1.2.3.4/30", "1.2.3.8/29", "1.2.3.16/28", "1.2.3.32/27",
-				"1.2.3.64/26", "1.2.3.128/25", "1.2.4.0/22", "1.2.8.0/21",
-				"1.2.16.0/20", "1.2.32.0/19", "1.2.64.0/18", "1.2.128.0/17",
-				"1.3.0.0/16", "1.4.0.0/14", "1.8.0.0/13", "1.16.0.0/12",
-				"1.32.0.0/11", "1.64.0.0/10", "1.128.0.0/9", "2.0.0.0/7",
-				"4.0.0.0/8", "5.0.0.0/14", "5.4.0.0/15", "5.6.0.0/22",
-				"5.6.4.0/23", "5.6.6.0/24", "5.6.7.0/29", "5.6.7.8/32",
+			[]string{"10.0.1.0/24", "10.0.2.0/24", "10.0.2.0/24", "10.0.3.0/24", "10.0.3.128/25"},
+			[][]string{
+				{"10.0.1.0/24"},
+				{"10.0.2.0/24", "10.0.3.0/24", "10.0.3.128/25"},
 			},
 		},
 		{
-			"255.255.255.255", "255.255.255.255",
-			[]string{
-				"255.255.255.255/32",
+			[]string{"2001:db8:1::/48", "10.0.0.0/9", "::/0", "2001:db8::/48", "10.128.0.0/9"},
+			[][]string{
+				{"10.0.0.0/9", "10.128.0.0/9"},
+				{"::/0", "2001:db8::/48", "2001:db8:1::/48"},
 			},
 		},
+		{nil, nil},
+	} {
+		var want [][]ipaddr.Prefix
+		for _, ss := range tt.want {
+			want = append(want, toPrefixes(ss))
+		}
+		if groups := ipaddr.AggregatableGroups(toPrefixes(tt.in)); !reflect.DeepEqual(groups, want) {
+			t.Errorf("#%d: got %v; want %v", i, groups, want)
+		}
+	}
+}
+
+func TestAggregateBGP(t *testing.T) {
+	for i, tt := range []struct {
+		in     []string
+		want   []string
+		atomic []bool
+	}{
 		{
-			"0.0.0.0", "255.255.255.255",
-			[]string{
-				"0.0.0.0/0",
-			},
+			[]string{"10.0.0.0/24", "10.0.1.0/24", "10.0.2.0/24", "10.0.8.0/24"},
+			[]string{"10.0.0.0/22", "10.0.8.0/24"},
+			[]bool{true, false},
 		},
 		{
-			"0.0.0.1", "255.255.255.254",
-			[]string{
-				"0.0.0.1/32", "0.0.0.2/31", "0.0.0.4/30", "0.0.0.8/29",
-				"0.0.0.16/28", "0.0.0.32/27", "0.0.0.64/26", "0.0.0.128/25",
-				"0.0.1.0/24", "0.0.2.0/23", "0.0.4.0/22", "0.0.8.0/21",
-				"0.0.16.0/20", "0.0.32.0/19", "0.0.64.0/18", "0.0.128.0/17",
-				"0.1.0.0/16", "0.2.0.0/15", "0.4.0.0/14", "0.8.0.0/13",
-				"0.16.0.0/12", "0.32.0.0/11", "0.64.0.0/10", "0.128.0.0/9",
-				"1.0.0.0/8", "2.0.0.0/7", "4.0.0.0/6", "8.0.0.0/5",
-				"16.0.0.0/4", "32.0.0.0/3", "64.0.0.0/2", "128.0.0.0/2",
-				"192.0.0.0/3", "224.0.0.0/4", "240.0.0.0/5", "248.0.0.0/6",
-				"252.0.0.0/7", "254.0.0.0/8", "255.0.0.0/9", "255.128.0.0/10",
-				"255.192.0.0/11", "255.224.0.0/12", "255.240.0.0/13", "255.248.0.0/14",
-				"255.252.0.0/15", "255.254.0.0/16", "255.255.0.0/17", "255.255.128.0/18",
-				"255.255.192.0/19", "255.255.224.0/20", "255.255.240.0/21", "255.255.248.0/22",
-				"255.255.252.0/23", "255.255.254.0/24", "255.255.255.0/25", "255.255.255.128/26",
-				"255.255.255.192/27", "255.255.255.224/28", "255.255.255.240/29", "255.255.255.248/30",
-				"255.255.255.252/31", "255.255.255.254/32",
-			},
+			[]string{"10.0.0.0/24", "10.0.1.0/24", "10.0.2.0/24", "10.0.3.0/24"},
+			[]string{"10.0.0.0/22"},
+			[]bool{false},
 		},
-
-		// IPv6 prefixes
 		{
-			"2001:db8:1::", "2001:db8:2::",
-			[]string{
-				"2001:db8:1::/48",
-				"2001:db8:2::/128",
-			},
+			[]string{"10.0.0.0/24", "10.0.2.0/24"},
+			[]string{"10.0.0.0/24", "10.0.2.0/24"},
+			[]bool{false, false},
 		},
+		{nil, nil, []bool{}},
+
 		{
-			"ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff", "ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff",
-			[]string{
-				"ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff/128",
-			},
+			[]string{"2001:db8::/34", "2001:db8:4000::/34", "2001:db8:8000::/34"},
+			[]string{"2001:db8::/32"},
+			[]bool{true},
 		},
+
 		{
-			"::", "ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff",
-			[]string{
-				"::/0",
-			},
+			[]string{"10.0.0.0/8", "2001:db8::/32"},
+			[]string{"10.0.0.0/8", "2001:db8::/32"},
+			[]bool{false, false},
 		},
 		{
-			"::1", "ffff:ffff:ffff:ffff:ffff:ffff:ffff:fffe",
-			[]string{
-				"::1/128", "::2/127", "::4/126", "::8/125",
-				"::10/124", "::20/123", "::40/122", "::80/121",
-				"::100/120", "::200/119", "::400/118", "::800/117",
-				"::1000/116", "::2000/115", "::4000/114", "::8000/113",
-				"::1:0/112", "::2:0/111", "::4:0/110", "::8:0/109",
-				"::10:0/108", "::20:0/107", "::40:0/106", "::80:0/105",
-				"::100:0/104", "::200:0/103", "::400:0/102", "::800:0/101",
-				"::1000:0/100", "::2000:0/99", "::4000:0/98", "::8000:0/97",
-				"::1:0:0/96", "::2:0:0/95", "::4:0:0/94", "::8:0:0/93",
-				"::10:0:0/92", "::20:0:0/91", "::40:0:0/90", "::80:0:0/89",
-				"::100:0:0/88", "::200:0:0/87", "::400:0:0/86", "::800:0:0/85",
-				"::1000:0:0/84", "::2000:0:0/83", "::4000:0:0/82", "::8000:0:0/81",
-				"::1:0:0:0/80", "::2:0:0:0/79", "::4:0:0:0/78", "::8:0:0:0/77",
-				"::10:0:0:0/76", "::20:0:0:0/75", "::40:0:0:0/74", "::80:0:0:0/73",
-				"::100:0:0:0/72", "::200:0:0:0/71", "::400:0:0:0/70", "::800:0:0:0/69",
-				"::1000:0:0:0/68", "::2000:0:0:0/67", "::4000:0:0:0/66", "::8000:0:0:0/65",
-				"0:0:0:1::/64", "0:0:0:2::/63", "0:0:0:4::/62", "0:0:0:8::/61",
-				"0:0:0:10::/60", "0:0:0:20::/59", "0:0:0:40::/58", "0:0:0:80::/57",
-				"0:0:0:100::/56", "0:0:0:200::/55", "0:0:0:400::/54", "0:0:0:800::/53",
-				"0:0:0:1000::/52", "0:0:0:2000::/51", "0:0:0:4000::/50", "0:0:0:8000::/49",
-				"0:0:1::/48", "0:0:2::/47", "0:0:4::/46", "0:0:8::/45",
-				"0:0:10::/44", "0:0:20::/43", "0:0:40::/42", "0:0:80::/41",
-				"0:0:100::/40", "0:0:200::/39", "0:0:400::/38", "0:0:800::/37",
-				"0:0:1000::/36", "0:0:2000::/35", "0:0:4000::/34", "0:0:8000::/33",
-				"0:1::/32", "0:2::/31", "0:4::/30", "0:8::/29",
-				"0:10::/28", "0:20::/27", "0:40::/26", "0:80::/25",
-				"0:100::/24", "0:200::/23", "0:400::/22", "0:800::/21",
-				"0:1000::/20", "0:2000::/19", "0:4000::/18", "0:8000::/17",
-				"1::/16", "2::/15", "4::/14", "8::/13",
-				"10::/12", "20::/11", "40::/10", "80::/9",
-				"ffff:ffff:ffff:ffff:ffff:ffff:ffff:ff00/121", "ffff:ffff:ffff:ffff:ffff:ffff:ffff:ff80/122", "ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffc0/123", "ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffe0/124",
-				"ffff:ffff:ffff:ffff:ffff:ffff:ffff:fff0/125", "ffff:ffff:ffff:ffff:ffff:ffff:ffff:fff8/126", "ffff:ffff:ffff:ffff:ffff:ffff:ffff:fffc/127", "ffff:ffff:ffff:ffff:ffff:ffff:ffff:fffe/128",
-				"ffff:ffff:ffff:ffff:ffff:ffff:ffff::/113", "ffff:ffff:ffff:ffff:ffff:ffff:ffff:8000/114", "ffff:ffff:ffff:ffff:ffff:ffff:ffff:c000/115", "ffff:ffff:ffff:ffff:ffff:ffff:ffff:e000/116",
-				"ffff:ffff:ffff:ffff:ffff:ffff:ffff:f000/117", "ffff:ffff:ffff:ffff:ffff:ffff:ffff:f800/118", "ffff:ffff:ffff:ffff:ffff:ffff:ffff:fc00/119", "ffff:ffff:ffff:ffff:ffff:ffff:ffff:fe00/120",
-				"ffff:ffff:ffff:ffff:ffff:ffff:ff00::/105", "ffff:ffff:ffff:ffff:ffff:ffff:ff80::/106", "ffff:ffff:ffff:ffff:ffff:ffff:ffc0::/107", "ffff:ffff:ffff:ffff:ffff:ffff:ffe0::/108",
-				"ffff:ffff:ffff:ffff:ffff:ffff:fff0::/109", "ffff:ffff:ffff:ffff:ffff:ffff:fff8::/110", "ffff:ffff:ffff:ffff:ffff:ffff:fffc::/111", "ffff:ffff:ffff:ffff:ffff:ffff:fffe::/112",
-				"ffff:ffff:ffff:ffff:ffff:ffff::/97", "ffff:ffff:ffff:ffff:ffff:ffff:8000::/98", "ffff:ffff:ffff:ffff:ffff:ffff:c000::/99", "ffff:ffff:ffff:ffff:ffff:ffff:e000::/100",
-				"ffff:ffff:ffff:ffff:ffff:ffff:f000::/101", "ffff:ffff:ffff:ffff:ffff:ffff:f800::/102", "ffff:ffff:ffff:ffff:ffff:ffff:fc00::/103", "ffff:ffff:ffff:ffff:ffff:ffff:fe00::/104",
-				"ffff:ffff:ffff:ffff:ffff:ff00::/89", "ffff:ffff:ffff:ffff:ffff:ff80::/90", "ffff:ffff:ffff:ffff:ffff:ffc0::/91", "ffff:ffff:ffff:ffff:ffff:ffe0::/92",
-				"ffff:ffff:ffff:ffff:ffff:fff0::/93", "ffff:ffff:ffff:ffff:ffff:fff8::/94", "ffff:ffff:ffff:ffff:ffff:fffc::/95", "ffff:ffff:ffff:ffff:ffff:fffe::/96",
-				"ffff:ffff:ffff:ffff:ffff::/81", "ffff:ffff:ffff:ffff:ffff:8000::/82", "ffff:ffff:ffff:ffff:ffff:c000::/83", "ffff:ffff:ffff:ffff:ffff:e000::/84",
-				"ffff:ffff:ffff:ffff:ffff:f000::/85", "ffff:ffff:ffff:ffff:ffff:f800::/86", "ffff:ffff:ffff:ffff:ffff:fc00::/87", "ffff:ffff:ffff:ffff:ffff:fe00::/88",
-				"ffff:ffff:ffff:ffff:ff00::/73", "ffff:ffff:ffff:ffff:ff80::/74", "ffff:ffff:ffff:ffff:ffc0::/75", "ffff:ffff:ffff:ffff:ffe0::/76",
-				"ffff:ffff:ffff:ffff:fff0::/77", "ffff:ffff:ffff:ffff:fff8::/78", "ffff:ffff:ffff:ffff:fffc::/79", "ffff:ffff:ffff:ffff:fffe::/80",
-				"ffff:ffff:ffff:ffff::/65", "ffff:ffff:ffff:ffff:8000::/66", "ffff:ffff:ffff:ffff:c000::/67", "ffff:ffff:ffff:ffff:e000::/68",
-				"ffff:ffff:ffff:ffff:f000::/69", "ffff:ffff:ffff:ffff:f800::/70", "ffff:ffff:ffff:ffff:fc00::/71", "ffff:ffff:ffff:ffff:fe00::/72",
-				"ffff:ffff:ffff:ff00::/57", "ffff:ffff:ffff:ff80::/58", "ffff:ffff:ffff:ffc0::/59", "ffff:ffff:ffff:ffe0::/60",
-				"ffff:ffff:ffff:fff0::/61", "ffff:ffff:ffff:fff8::/62", "ffff:ffff:ffff:fffc::/63", "ffff:ffff:ffff:fffe::/64",
-				"ffff:ffff:ffff::/49", "ffff:ffff:ffff:8000::/50", "ffff:ffff:ffff:c000::/51", "ffff:ffff:ffff:e000::/52",
-				"ffff:ffff:ffff:f000::/53", "ffff:ffff:ffff:f800::/54", "ffff:ffff:ffff:fc00::/55", "ffff:ffff:ffff:fe00::/56",
-				"ffff:ffff:ff00::/41", "ffff:ffff:ff80::/42", "ffff:ffff:ffc0::/43", "ffff:ffff:ffe0::/44",
-				"ffff:ffff:fff0::/45", "ffff:ffff:fff8::/46", "ffff:ffff:fffc::/47", "ffff:ffff:fffe::/48",
-				"ffff:ffff::/33", "ffff:ffff:8000::/34", "ffff:ffff:c000::/35", "ffff:ffff:e000::/36",
-				"ffff:ffff:f000::/37", "ffff:ffff:f800::/38", "ffff:ffff:fc00::/39", "ffff:ffff:fe00::/40",
-				"ffff:ff00::/25", "ffff:ff80::/26", "ffff:ffc0::/27", "ffff:ffe0::/28",
-				"ffff:fff0::/29", "ffff:fff8::/30", "ffff:fffc::/31", "ffff:fffe::/32",
-				"ffff::/17", "ffff:8000::/18", "ffff:c000::/19", "ffff:e000::/20",
-				"ffff:f000::/21", "ffff:f800::/22", "ffff:fc00::/23", "ffff:fe00::/24",
-				"ff00::/9", "ff80::/10", "ffc0::/11", "ffe0::/12",
-				"fff0::/13", "fff8::/14", "fffc::/15", "fffe::/16",
-				"100::/8", "200::/7", "400::/6", "800::/5",
-				"1000::/4", "2000::/3", "4000::/2", "8000::/2",
-				"c000::/3", "e000::/4", "f000::/5", "f800::/6",
-				"fc00::/7", "fe00::/8",
-			},
+			[]string{"10.0.0.0/24", "10.0.1.0/24", "10.0.2.0/24", "2001:db8::/34", "2001:db8:4000::/34", "2001:db8:8000::/33"},
+			[]string{"10.0.0.0/22", "2001:db8::/32"},
+			[]bool{true, false},
 		},
 	} {
-		fip := net.ParseIP(tt.first)
-		if fip == nil {
-			t.Fatalf("non-IP address: %s", tt.first)
-		}
-		lip := net.ParseIP(tt.last)
-		if lip == nil {
-			t.Fatalf("non-IP address: %s", tt.last)
-		}
-		want := toPrefixes(tt.want)
-		sort.Sort(byAscending(want))
-		out := ipaddr.Summarize(fip, lip)
-		if !reflect.DeepEqual(out, want) {
-			t.Errorf("#%d: got %v; want %v", i, out, want)
+		ps, atomic := ipaddr.AggregateBGP(toPrefixes(tt.in))
+		if !reflect.DeepEqual(ps, toPrefixes(tt.want)) || !reflect.DeepEqual(atomic, tt.atomic) {
+			t.Errorf("#%d: got %v, %v; want %v, %v", i, ps, atomic, tt.want, tt.atomic)
 		}
 	}
 
-	ipaddr.Summarize(nil, nil)
+	in := toPrefix("2001:db8::/32").Exclude(toPrefix("2001:db8::1/128"))
+	ps, atomic := ipaddr.AggregateBGP(in)
+	if want := toPrefixes([]string{"2001:db8::/32"}); !reflect.DeepEqual(ps, want) || !reflect.DeepEqual(atomic, []bool{true}) {
+		t.Errorf("got %v, %v; want %v, %v", ps, atomic, want, []bool{true})
+	}
 }
 
-func TestSupernet(t *testing.T) {
+func TestAggregateSafe(t *testing.T) {
 	for i, tt := range []struct {
-		in   []string
-		want string
+		in         []string
+		ipv4, ipv6 []string
 	}{
-		// IPv4 prefixes
 		{
-			[]string{
-				"192.0.2.0/25", "192.0.2.128/25",
-			},
-			"192.0.2.0/24",
+			[]string{"2001:db8::/33", "10.0.0.0/9", "2001:db8:8000::/33", "10.128.0.0/9", "192.0.2.0/24"},
+			[]string{"10.0.0.0/8", "192.0.2.0/24"},
+			[]string{"2001:db8::/32"},
 		},
 		{
-			[]string{
-				"192.0.2.0/27", "192.0.2.32/27", "192.0.2.64/27", "192.0.2.96/27",
-				"192.0.2.128/27", "192.0.2.160/27", "192.0.2.192/27", "192.0.2.224/27",
-			},
-			"192.0.2.0/24",
+			[]string{"192.0.2.0/24", "2001:db8::/64", "::/0"},
+			[]string{"192.0.2.0/24"},
+			[]string{"::/0"},
 		},
 		{
-			[]string{
-				"192.0.2.0/28", "192.0.2.16/28", "192.0.2.32/28", "192.0.2.48/28",
-				"192.0.2.64/28", "192.0.2.80/28", "192.0.2.96/28", "192.0.2.112/28",
-				"192.0.2.128/28", "192.0.2.144/28", "192.0.2.160/28", "192.0.2.176/28",
-				"192.0.2.192/28", "192.0.2.208/28", "192.0.2.224/28", "192.0.2.240/28",
-			},
-			"192.0.2.0/24",
+			[]string{"2001:db8::/64"},
+			nil,
+			[]string{"2001:db8::/64"},
 		},
 		{
-			[]string{
-				"10.40.101.1/32", "10.40.102.1/32", "11.40.103.1/32",
+			[]string{"192.0.2.0/25", "192.0.2.128/25"},
+			[]string{"192.0.2.0/24"},
+			nil,
+		},
+		{nil, nil, nil},
+	} {
+		ipv4, ipv6 := ipaddr.AggregateSafe(toPrefixes(tt.in))
+		if !reflect.DeepEqual(ipv4, toPrefixes(tt.ipv4)) || !reflect.DeepEqual(ipv6, toPrefixes(tt.ipv6)) {
+			t.Errorf("#%d: got %v, %v; want %v, %v", i, ipv4, ipv6, tt.ipv4, tt.ipv6)
+		}
+	}
+}
+
+func TestAggregateStats(t *testing.T) {
+	for i, tt := range []struct {
+		in        []string
+		want      []string
+		nin, nout int
+	}{
+		{
+			[]string{"192.0.2.0/26", "192.0.2.64/26", "192.0.2.128/25", "192.0.2.0/26", "198.51.100.0/25", "198.51.100.128/25"},
+			[]string{"192.0.2.0/24", "198.51.100.0/24"},
+			5, 2,
+		},
+		{
+			[]string{"2001:db8::/64", "2001:db8:0:1::/64", "2001:db8:1::/48"},
+			[]string{"2001:db8::/63", "2001:db8:1::/48"},
+			3, 2,
+		},
+		{
+			[]string{"10.0.0.0/24", "2001:db8::/64", "2001:db8:0:1::/64", "10.0.0.0/24"},
+			[]string{"10.0.0.0/24", "2001:db8::/63"},
+			3, 2,
+		},
+		{nil, nil, 0, 0},
+	} {
+		ps, nin, nout := ipaddr.AggregateStats(toPrefixes(tt.in))
+		if !reflect.DeepEqual(ps, toPrefixes(tt.want)) {
+			t.Errorf("#%d: got %v; want %v", i, ps, tt.want)
+		}
+		if nin != tt.nin || nout != tt.nout {
+			t.Errorf("#%d: got %v, %v; want %v, %v", i, nin, nout, tt.nin, tt.nout)
+		}
+	}
+}
+
+func TestAggregateToBytes(t *testing.T) {
+	for i, tt := range []struct {
+		in       []string
+		maxBytes int
+		want     []string
+	}{
+		{
+			[]string{
+				"10.0.0.0/24", "10.0.1.0/24", "10.0.3.0/24",
+				"10.0.16.0/23", "10.0.18.0/23", "10.0.21.0/24",
+				"10.0.64.0/24", "10.0.66.0/24",
+				"10.0.128.0/24", "10.0.129.0/24",
+			},
+			16,
+			[]string{"10.0.0.0/22", "10.0.16.0/21", "10.0.64.0/22", "10.0.128.0/23"},
+		},
+		{[]string{"10.0.0.0/24", "10.0.1.0/24", "10.0.3.0/24"}, 8, []string{"10.0.0.0/23", "10.0.3.0/24"}},
+		{[]string{"10.0.0.0/24", "10.0.1.0/24", "10.0.3.0/24"}, 7, []string{"10.0.0.0/22"}},
+		{[]string{"10.0.0.0/24", "10.0.1.0/24", "10.0.3.0/24"}, 0, []string{"10.0.0.0/22"}},
+		{[]string{"10.0.0.0/8", "11.0.0.0/8"}, 3, []string{"10.0.0.0/7"}},
+		{nil, 4, nil},
+
+		{[]string{"2001:db8::/64", "2001:db8:0:3::/64", "2001:db8:1::/48"}, 16, []string{"2001:db8::/62", "2001:db8:1::/48"}},
+
+		{[]string{"10.0.0.0/24", "10.0.2.0/24", "2001:db8::/64", "2001:db8:0:2::/64"}, 13, []string{"10.0.0.0/22", "2001:db8::/62"}},
+	} {
+		out := ipaddr.AggregateToBytes(toPrefixes(tt.in), tt.maxBytes)
+		if want := toPrefixes(tt.want); !reflect.DeepEqual(out, want) {
+			t.Errorf("#%d: got %v; want %v", i, out, want)
+		}
+		if len(out) > 1 {
+			n := 0
+			for _, p := range out {
+				n += 1 + (p.Len()+7)/8
+			}
+			if n > tt.maxBytes {
+				t.Errorf("#%d: got %d bytes; want less than or equal to %d", i, n, tt.maxBytes)
+			}
+		}
+	}
+
+	out := ipaddr.AggregateToBytes(toPrefixes([]string{"10.0.0.0/8", "2001:db8::/32"}), 1)
+	if want := toPrefixes([]string{"10.0.0.0/8", "2001:db8::/32"}); !reflect.DeepEqual(out, want) {
+		t.Errorf("got %v; want %v", out, want)
+	}
+}
+
+func TestAggregateToLimit(t *testing.T) {
+	for i, tt := range []struct {
+		in   []string
+		maxN int
+		want []string
+	}{
+		{
+			[]string{
+				"10.0.0.0/24", "10.0.1.0/24", "10.0.3.0/24",
+				"10.0.16.0/23", "10.0.18.0/23", "10.0.21.0/24",
+				"10.0.64.0/24", "10.0.66.0/24",
+				"10.0.128.0/24", "10.0.129.0/24",
+			},
+			4,
+			[]string{"10.0.0.0/22", "10.0.16.0/21", "10.0.64.0/22", "10.0.128.0/23"},
+		},
+		{[]string{"10.0.0.0/24", "10.0.1.0/24", "10.0.3.0/24"}, 2, []string{"10.0.0.0/23", "10.0.3.0/24"}},
+		{[]string{"10.0.0.0/24", "10.0.1.0/24", "10.0.3.0/24"}, 0, []string{"10.0.0.0/22"}},
+		{nil, 4, nil},
+
+		{[]string{"2001:db8::/64", "2001:db8:0:3::/64", "2001:db8:1::/48"}, 2, []string{"2001:db8::/62", "2001:db8:1::/48"}},
+
+		{[]string{"10.0.0.0/8", "2001:db8::/32"}, 1, []string{"10.0.0.0/8", "2001:db8::/32"}},
+		{[]string{"10.0.0.0/24", "10.0.2.0/24", "2001:db8::/64", "2001:db8:0:2::/64"}, 2, []string{"10.0.0.0/22", "2001:db8::/62"}},
+		{[]string{"10.0.0.0/8", "192.0.2.0/24"}, 1, []string{"10.0.0.0/8", "192.0.2.0/24"}},
+	} {
+		out := ipaddr.AggregateToLimit(toPrefixes(tt.in), tt.maxN)
+		if want := toPrefixes(tt.want); !reflect.DeepEqual(out, want) {
+			t.Errorf("#%d: got %v; want %v", i, out, want)
+		}
+	}
+}
+
+func TestAggregateWithGap(t *testing.T) {
+	for i, tt := range []struct {
+		in     []string
+		maxGap *big.Int
+		want   []string
+	}{
+		{[]string{"10.0.0.0/23", "10.0.3.0/24"}, big.NewInt(255), []string{"10.0.0.0/23", "10.0.3.0/24"}},
+		{[]string{"10.0.0.0/23", "10.0.3.0/24"}, big.NewInt(256), []string{"10.0.0.0/22"}},
+		{[]string{"10.0.0.0/24", "10.0.2.0/24"}, big.NewInt(255), []string{"10.0.0.0/24", "10.0.2.0/24"}},
+		{[]string{"10.0.0.0/24", "10.0.2.0/24"}, big.NewInt(256), []string{"10.0.0.0/22"}},
+		{[]string{"10.0.0.0/24", "10.0.2.0/24"}, big.NewInt(512), []string{"10.0.0.0/22"}},
+		{[]string{"10.0.7.0/24", "10.0.8.0/24"}, big.NewInt(1 << 12), []string{"10.0.7.0/24", "10.0.8.0/24"}},
+		{[]string{"10.0.0.0/24", "10.0.2.0/24", "2001:db8::/64"}, big.NewInt(256), []string{"10.0.0.0/22", "2001:db8::/64"}},
+		{[]string{"10.0.0.0/24", "10.0.1.0/24", "10.0.3.0/24"}, nil, []string{"10.0.0.0/23", "10.0.3.0/24"}},
+		{[]string{"10.0.0.0/24", "10.0.1.0/24", "10.0.3.0/24"}, big.NewInt(0), []string{"10.0.0.0/23", "10.0.3.0/24"}},
+		{[]string{"10.0.0.0/24", "10.0.2.0/24", "10.0.7.0/24", "10.0.8.0/24"}, big.NewInt(512), []string{"10.0.0.0/22", "10.0.7.0/24", "10.0.8.0/24"}},
+		{[]string{"10.0.0.0/24", "10.0.2.0/24", "10.0.7.0/24", "10.0.8.0/24"}, big.NewInt(1 << 12), []string{"10.0.0.0/21", "10.0.8.0/24"}},
+		{[]string{"10.0.0.0/24", "10.0.2.0/24", "10.0.7.0/24", "10.0.9.0/24"}, big.NewInt(1 << 12), []string{"10.0.0.0/20"}},
+
+		{[]string{"2001:db8::/64", "2001:db8:0:3::/64"}, big.NewInt(0), []string{"2001:db8::/64", "2001:db8:0:3::/64"}},
+		{[]string{"2001:db8::/64", "2001:db8:0:3::/64"}, new(big.Int).Lsh(big.NewInt(1), 65), []string{"2001:db8::/62"}},
+	} {
+		out := ipaddr.AggregateWithGap(toPrefixes(tt.in), tt.maxGap)
+		if want := toPrefixes(tt.want); !reflect.DeepEqual(out, want) {
+			t.Errorf("#%d: got %v; want %v", i, out, want)
+		}
+	}
+}
+
+func TestBestMatch(t *testing.T) {
+	ps := toPrefixes([]string{"0.0.0.0/0", "192.168.0.0/16", "192.168.1.0/24", "192.168.1.0/25", "::/0", "2001:db8::/32", "2001:db8:1::/48"})
+	for i, tt := range []struct {
+		in   net.IP
+		want *ipaddr.Prefix
+		ok   bool
+	}{
+		{net.ParseIP("192.168.1.200"), toPrefix("192.168.1.0/24"), true},
+		{net.ParseIP("192.168.1.1"), toPrefix("192.168.1.0/25"), true},
+		{net.ParseIP("192.168.2.1"), toPrefix("192.168.0.0/16"), true},
+		{net.ParseIP("198.51.100.1"), toPrefix("0.0.0.0/0"), true},
+		{net.ParseIP("2001:db8:1::1"), toPrefix("2001:db8:1::/48"), true},
+		{net.ParseIP("2001:db9::1"), toPrefix("::/0"), true},
+	} {
+		p, l, ok := ipaddr.BestMatch(tt.in, ps)
+		if ok != tt.ok || !p.Equal(tt.want) || l != tt.want.Len() {
+			t.Errorf("#%d: got %v, %v, %v; want %v, %v, %v", i, p, l, ok, tt.want, tt.want.Len(), tt.ok)
+		}
+	}
+	if _, _, ok := ipaddr.BestMatch(net.ParseIP("192.0.2.1"), toPrefixes([]string{"192.168.0.0/16", "2001:db8::/32"})); ok {
+		t.Error("should not match")
+	}
+}
+
+func TestCanonical(t *testing.T) {
+	for i, tt := range []struct {
+		in   []string
+		want []string
+	}{
+		{
+			[]string{"2001:db8::/32", "192.0.2.0/24", "::/0", "10.0.0.0/8", "192.0.2.0/25", "10.0.0.0/8", "2001:db8::/48"},
+			[]string{"10.0.0.0/8", "192.0.2.0/24", "192.0.2.0/25", "::/0", "2001:db8::/32", "2001:db8::/48"},
+		},
+		{
+			[]string{"0.0.0.0/0", "::/0"},
+			[]string{"0.0.0.0/0", "::/0"},
+		},
+		{nil, nil},
+	} {
+		in := toPrefixes(tt.in)
+		out := ipaddr.Canonical(in)
+		if !reflect.DeepEqual(out, toPrefixes(tt.want)) {
+			t.Errorf("#%d: got %v; want %v", i, out, tt.want)
+		}
+		if !reflect.DeepEqual(in, toPrefixes(tt.in)) {
+			t.Errorf("#%d: %v is corrupted; want %v", i, in, tt.in)
+		}
+
+		rev := make([]ipaddr.Prefix, len(in))
+		for j := range in {
+			rev[len(in)-1-j] = in[j]
+		}
+		var b1, b2 []byte
+		for _, p := range out {
+			b1 = append(p.AppendString(b1), '\n')
+		}
+		for _, p := range ipaddr.Canonical(rev) {
+			b2 = append(p.AppendString(b2), '\n')
+		}
+		if !bytes.Equal(b1, b2) {
+			t.Errorf("#%d: got %q; want %q", i, b2, b1)
+		}
+	}
+}
+
+func TestCommonPrefixAddrs(t *testing.T) {
+	for i, tt := range []struct {
+		in   []string
+		want string
+	}{
+		{[]string{"192.168.0.5", "192.168.1.9"}, "192.168.0.0/23"},
+		{[]string{"192.168.0.5"}, "192.168.0.5/32"},
+		{[]string{"192.168.0.5", "192.168.0.5"}, "192.168.0.5/32"},
+		{[]string{"192.168.0.1", "192.168.0.2", "192.168.0.200"}, "192.168.0.0/24"},
+		{[]string{"0.0.0.1", "255.255.255.255"}, "0.0.0.0/0"},
+
+		{[]string{"2001:db8::1", "2001:db8::2"}, "2001:db8::/126"},
+		{[]string{"2001:db8:0:1::1", "2001:db8:0:2::1"}, "2001:db8::/62"},
+		{[]string{"::1", "8000::"}, "::/0"},
+
+		{[]string{"192.168.0.1", "2001:db8::1"}, ""},
+		{[]string{"2001:db8::1", "192.168.0.1"}, ""},
+		{nil, ""},
+	} {
+		var ips []net.IP
+		for _, s := range tt.in {
+			ips = append(ips, net.ParseIP(s))
+		}
+		p, err := ipaddr.CommonPrefixAddrs(ips)
+		if tt.want == "" {
+			if err == nil {
+				t.Errorf("#%d: got %v; want an error", i, p)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("#%d: %v", i, err)
+			continue
+		}
+		if !reflect.DeepEqual(p, toPrefix(tt.want)) {
+			t.Errorf("#%d: got %v; want %v", i, p, tt.want)
+		}
+	}
+}
+
+func TestCompare(t *testing.T) {
+	for i, tt := range []struct {
+		in []ipaddr.Prefix
+		n  int
+	}{
+		{toPrefixes([]string{"192.0.2.0/23", "192.0.2.0/24"}), -1},
+		{toPrefixes([]string{"192.0.2.0/24", "192.0.2.0/24"}), 0},
+		{toPrefixes([]string{"192.0.2.0/25", "192.0.2.0/24"}), +1},
+
+		{toPrefixes([]string{"192.0.2.0/24", "198.51.100.0/24"}), -1},
+		{toPrefixes([]string{"198.51.100.0/24", "198.51.100.0/24"}), 0},
+		{toPrefixes([]string{"203.0.113.0/24", "198.51.100.0/24"}), +1},
+
+		{toPrefixes([]string{"2001:db8:1::/47", "2001:db8:1::/48"}), -1},
+		{toPrefixes([]string{"2001:db8:1::/48", "2001:db8:1::/48"}), 0},
+		{toPrefixes([]string{"2001:db8:1::/49", "2001:db8:1::/48"}), +1},
+
+		{toPrefixes([]string{"2001:db8:1::/128", "2001:db8:1::1/128"}), -1},
+		{toPrefixes([]string{"2001:db8:1::1/128", "2001:db8:1::1/128"}), 0},
+		{toPrefixes([]string{"2001:db8:1::2/128", "2001:db8:1::1/128"}), +1},
+
+		{toPrefixes([]string{"192.0.2.1/24", "2001:db8:1::1/64"}), -1},
+		{toPrefixes([]string{"2001:db8:1::1/64", "192.0.2.1/24"}), +1},
+	} {
+		if n := ipaddr.Compare(&tt.in[0], &tt.in[1]); n != tt.n {
+			t.Errorf("#%d: got %v for %v; want %v", i, n, tt.in, tt.n)
+		}
+	}
+}
+
+func TestComplement(t *testing.T) {
+	for i, tt := range []struct {
+		afi  int
+		in   []string
+		want []ipaddr.Prefix
+	}{
+		{
+			1,
+			[]string{"10.0.0.0/8"},
+			toPrefixes([]string{
+				"0.0.0.0/5", "8.0.0.0/7", "11.0.0.0/8", "12.0.0.0/6",
+				"16.0.0.0/4", "32.0.0.0/3", "64.0.0.0/2", "128.0.0.0/1",
+			}),
+		},
+		{
+			1,
+			[]string{"0.0.0.0/1", "192.0.0.0/2", "2001:db8::/32"},
+			toPrefixes([]string{"128.0.0.0/2"}),
+		},
+		{
+			1,
+			[]string{"0.0.0.0/32", "255.255.255.255/32"},
+			ipaddr.Summarize(net.ParseIP("0.0.0.1"), net.ParseIP("255.255.255.254")),
+		},
+		{1, nil, toPrefixes([]string{"0.0.0.0/0"})},
+		{1, []string{"0.0.0.0/0"}, nil},
+		{1, []string{"0.0.0.0/1", "128.0.0.0/1"}, nil},
+
+		{
+			2,
+			[]string{"8000::/1", "4000::/2", "192.0.2.0/24"},
+			toPrefixes([]string{"::/2"}),
+		},
+		{2, []string{"192.0.2.0/24"}, toPrefixes([]string{"::/0"})},
+		{2, []string{"::/0"}, nil},
+
+		{0, []string{"192.0.2.0/24"}, nil},
+	} {
+		out := ipaddr.Complement(tt.afi, toPrefixes(tt.in))
+		if !reflect.DeepEqual(out, tt.want) {
+			t.Errorf("#%d: got %v; want %v", i, out, tt.want)
+		}
+	}
+}
+
+func TestContainmentDepth(t *testing.T) {
+	set := toPrefixes([]string{"10.0.0.0/8", "10.1.0.0/16", "10.1.2.0/24", "10.2.0.0/16", "::/0", "2001:db8::/32"})
+	for i, tt := range []struct {
+		in   string
+		want int
+	}{
+		{"10.1.2.0/24", 2},
+		{"10.1.2.128/25", 3},
+		{"10.1.0.0/16", 1},
+		{"10.0.0.0/8", 0},
+		{"192.0.2.0/24", 0},
+
+		{"2001:db8:1::/48", 2},
+		{"::/0", 0},
+	} {
+		if n := ipaddr.ContainmentDepth(toPrefix(tt.in), set); n != tt.want {
+			t.Errorf("#%d: got %v; want %v", i, n, tt.want)
+		}
+	}
+}
+
+func TestCountBlocks(t *testing.T) {
+	for i, tt := range []struct {
+		in   []string
+		l    int
+		want *big.Int
+	}{
+		{[]string{"192.0.2.0/25", "192.0.2.128/25"}, 24, big.NewInt(1)},
+		{[]string{"192.0.2.0/25", "198.51.100.0/26"}, 24, big.NewInt(2)},
+		{[]string{"192.0.0.0/22", "192.0.2.0/24", "192.0.3.7/32"}, 24, big.NewInt(4)},
+		{[]string{"10.0.0.0/8"}, 24, big.NewInt(1 << 16)},
+		{[]string{"192.0.2.0/24"}, 33, big.NewInt(0)},
+
+		{[]string{"2001:db8::/64", "2001:db8::1/128", "2001:db8:0:1::/64"}, 48, big.NewInt(1)},
+		{[]string{"2001:db8::/32"}, 64, big.NewInt(1 << 32)},
+
+		{[]string{"192.0.2.0/25", "2001:db8::/64", "2002::/64"}, 24, big.NewInt(3)},
+		{[]string{"::/16", "192.0.2.0/24", "0:1::/64"}, 16, big.NewInt(2)},
+		{nil, 24, big.NewInt(0)},
+	} {
+		if n := ipaddr.CountBlocks(toPrefixes(tt.in), tt.l); n.Cmp(tt.want) != 0 {
+			t.Errorf("#%d: got %v; want %v", i, n, tt.want)
+		}
+	}
+}
+
+func TestCoveringPrefixesOf(t *testing.T) {
+	for i, tt := range []struct {
+		in          net.IP
+		n           int
+		first, last string
+	}{
+		{net.ParseIP("192.0.2.1"), 33, "0.0.0.0/0", "192.0.2.1/32"},
+		{net.ParseIP("2001:db8::1"), 129, "::/0", "2001:db8::1/128"},
+		{nil, 0, "", ""},
+	} {
+		ps := ipaddr.CoveringPrefixesOf(tt.in)
+		if len(ps) != tt.n {
+			t.Errorf("#%d: got %v; want %v", i, len(ps), tt.n)
+			continue
+		}
+		if len(ps) == 0 {
+			continue
+		}
+		if ps[0].String() != tt.first || ps[len(ps)-1].String() != tt.last {
+			t.Errorf("#%d: got %v, %v; want %v, %v", i, ps[0], ps[len(ps)-1], tt.first, tt.last)
+		}
+		for j := range ps {
+			if ps[j].Len() != j || !ps[j].IPNet.Contains(tt.in) {
+				t.Errorf("#%d: %v must have length %d and contain %v", i, ps[j], j, tt.in)
+			}
+		}
+	}
+	ps := ipaddr.CoveringPrefixesOf(net.ParseIP("192.0.2.1"))
+	if want := toPrefix("192.0.0.0/22"); !ps[22].Equal(want) {
+		t.Errorf("got %v; want %v", ps[22], want)
+	}
+}
+
+func TestDensity(t *testing.T) {
+	for i, tt := range []struct {
+		parent   string
+		children []string
+		want     float64
+	}{
+		{"192.0.2.0/24", []string{"192.0.2.0/25", "192.0.2.128/26", "192.0.2.192/26"}, 1},
+		{"192.0.2.0/24", []string{"192.0.2.0/25", "192.0.2.0/26", "198.51.100.0/24"}, 0.5},
+		{"192.0.2.0/24", []string{"192.0.2.0/26", "192.0.2.255/32"}, 65.0 / 256},
+		{"192.0.2.0/24", []string{"192.0.0.0/16"}, 1},
+		{"192.0.2.0/24", []string{"2001:db8::/32"}, 0},
+		{"192.0.2.0/24", nil, 0},
+
+		{"2001:db8::/32", []string{"2001:db8::/33", "2001:db8:8000::/34"}, 0.75},
+	} {
+		parent := toPrefix(tt.parent)
+		if d := ipaddr.Density(parent, toPrefixes(tt.children)); d != tt.want {
+			t.Errorf("#%d: got %v; want %v", i, d, tt.want)
+		}
+	}
+}
+
+func TestEqualMapped(t *testing.T) {
+	for i, tt := range []struct {
+		a, b string
+		want bool
+	}{
+		{"::ffff:192.168.0.0/120", "192.168.0.0/24", true},
+		{"192.168.0.0/24", "::ffff:192.168.0.0/120", true},
+		{"::ffff:0:0/96", "0.0.0.0/0", true},
+		{"::ffff:192.168.0.1/128", "192.168.0.1/32", true},
+		{"::ffff:192.168.0.0/120", "::ffff:192.168.0.0/120", true},
+		{"192.168.0.0/24", "192.168.0.0/24", true},
+		{"::ffff:192.168.0.0/120", "192.168.0.0/25", false},
+		{"::ffff:192.168.0.0/120", "192.168.1.0/24", false},
+		{"::c0a8:0/120", "192.168.0.0/24", false},
+		{"::/0", "0.0.0.0/0", false},
+
+		{"2001:db8::/32", "2001:db8::/32", true},
+		{"2001:db8::/32", "2001:db8::/33", false},
+	} {
+		if ok := ipaddr.EqualMapped(toPrefix(tt.a), toPrefix(tt.b)); ok != tt.want {
+			t.Errorf("#%d: got %v; want %v", i, ok, tt.want)
+		}
+	}
+}
+
+func TestFindUnaligned(t *testing.T) {
+	raw := []struct {
+		IP  net.IP
+		Len int
+	}{
+		{net.ParseIP("192.168.1.5"), 24},
+		{net.ParseIP("192.168.1.0"), 24},
+		{net.ParseIP("192.168.1.5"), 32},
+		{net.ParseIP("0.0.0.0"), 0},
+		{net.ParseIP("192.168.1.0"), 33},
+		{nil, 24},
+		{net.ParseIP("2001:db8::1"), 64},
+		{net.ParseIP("2001:db8::"), 32},
+		{net.ParseIP("2001:db8::"), -1},
+	}
+	want := []int{0, 4, 5, 6, 8}
+	if idxs := ipaddr.FindUnaligned(raw); !reflect.DeepEqual(idxs, want) {
+		t.Errorf("got %v; want %v", idxs, want)
+	}
+	if idxs := ipaddr.FindUnaligned(nil); idxs != nil {
+		t.Errorf("got %v; want nil", idxs)
+	}
+}
+
+func TestGenerations(t *testing.T) {
+	for i, tt := range []struct {
+		ancestor, descendant string
+		n                    int
+		ok                   bool
+	}{
+		{"10.0.0.0/8", "10.1.0.0/16", 8, true},
+		{"0.0.0.0/0", "192.0.2.1/32", 32, true},
+		{"10.0.0.0/8", "10.0.0.0/8", 0, true},
+		{"10.1.0.0/16", "10.0.0.0/8", 0, false},
+		{"10.0.0.0/8", "192.0.2.0/24", 0, false},
+		{"::/0", "10.0.0.0/8", 0, false},
+
+		{"2001:db8::/32", "2001:db8:1::/48", 16, true},
+		{"2001:db8::/32", "2001:db9::/48", 0, false},
+	} {
+		n, ok := ipaddr.Generations(toPrefix(tt.ancestor), toPrefix(tt.descendant))
+		if n != tt.n || ok != tt.ok {
+			t.Errorf("#%d: got %v, %v; want %v, %v", i, n, ok, tt.n, tt.ok)
+		}
+	}
+}
+
+func TestHammingDistance(t *testing.T) {
+	for i, tt := range []struct {
+		a, b string
+		want int
+		ok   bool
+	}{
+		{"192.0.2.0/24", "192.0.2.0/24", 0, true},
+		{"192.0.2.0/24", "192.0.3.0/24", 1, true},
+		{"10.0.0.0/8", "138.0.0.0/8", 1, true},
+		{"192.0.2.1/32", "193.0.2.0/32", 2, true},
+		{"0.0.0.0/32", "255.255.255.255/32", 32, true},
+
+		{"2001:db8::/32", "2001:db8::/48", 0, true},
+		{"2001:db8::1/128", "3001:db8::8000:0:0:0/128", 3, true},
+		{"::/128", "ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff/128", 128, true},
+
+		{"192.0.2.0/24", "2001:db8::/32", 0, false},
+	} {
+		a, b := toPrefix(tt.a), toPrefix(tt.b)
+		n, err := ipaddr.HammingDistance(a, b)
+		if (err == nil) != tt.ok || n != tt.want {
+			t.Errorf("#%d: got %v, %v; want %v, %v", i, n, err, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestInsertNonOverlapping(t *testing.T) {
+	for i, tt := range []struct {
+		set  []string
+		in   string
+		want []string
+	}{
+		{[]string{"10.0.1.0/24"}, "10.0.0.0/16", nil},
+		{[]string{"10.0.0.0/16"}, "10.0.1.0/24", nil},
+		{[]string{"10.0.0.0/16", "192.0.2.0/24"}, "192.0.2.0/24", nil},
+		{[]string{"10.0.1.0/24"}, "10.0.2.0/24", []string{"10.0.1.0/24", "10.0.2.0/24"}},
+		{[]string{"10.0.1.0/24"}, "2001:db8::/32", []string{"10.0.1.0/24", "2001:db8::/32"}},
+		{nil, "2001:db8::/32", []string{"2001:db8::/32"}},
+	} {
+		set := toPrefixes(tt.set)
+		out, err := ipaddr.InsertNonOverlapping(set, toPrefix(tt.in))
+		if tt.want == nil {
+			if err == nil {
+				t.Errorf("#%d: got %v; want an error", i, out)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("#%d: %v", i, err)
+			continue
+		}
+		if want := toPrefixes(tt.want); !reflect.DeepEqual(out, want) {
+			t.Errorf("#%d: got %v; want %v", i, out, want)
+		}
+		if !reflect.DeepEqual(set, toPrefixes(tt.set)) {
+			t.Errorf("#%d: %v is corrupted; want %v", i, set, tt.set)
+		}
+	}
+}
+
+func TestInsertSplit(t *testing.T) {
+	for i, tt := range []struct {
+		set  []string
+		in   string
+		want []string
+	}{
+		{[]string{"10.0.1.0/24"}, "10.0.0.0/22", []string{"10.0.1.0/24", "10.0.0.0/24", "10.0.2.0/23"}},
+		{[]string{"10.0.0.0/16"}, "10.0.1.0/24", []string{"10.0.0.0/16"}},
+		{[]string{"10.0.1.0/24"}, "10.0.2.0/24", []string{"10.0.1.0/24", "10.0.2.0/24"}},
+		{[]string{"2001:db8::/33", "192.0.2.0/24"}, "2001:db8::/32", []string{"2001:db8::/33", "192.0.2.0/24", "2001:db8:8000::/33"}},
+	} {
+		out := ipaddr.InsertSplit(toPrefixes(tt.set), toPrefix(tt.in))
+		if want := toPrefixes(tt.want); !reflect.DeepEqual(out, want) {
+			t.Errorf("#%d: got %v; want %v", i, out, want)
+		}
+	}
+}
+
+func TestIntersect(t *testing.T) {
+	for i, tt := range []struct {
+		a, b string
+		want string
+	}{
+		{"10.0.0.0/8", "10.1.0.0/16", "10.1.0.0/16"},
+		{"10.1.0.0/16", "10.0.0.0/8", "10.1.0.0/16"},
+		{"10.0.0.0/8", "10.0.0.0/8", "10.0.0.0/8"},
+		{"0.0.0.0/0", "192.0.2.1/32", "192.0.2.1/32"},
+		{"10.0.0.0/8", "11.0.0.0/8", ""},
+		{"10.0.0.0/8", "::/0", ""},
+		{"::/0", "10.0.0.0/8", ""},
+
+		{"2001:db8::/32", "2001:db8:1::/48", "2001:db8:1::/48"},
+		{"2001:db8:1::/48", "2001:db8:2::/48", ""},
+	} {
+		a, b := toPrefix(tt.a), toPrefix(tt.b)
+		if p := ipaddr.Intersect(a, b); !reflect.DeepEqual(p, toPrefix(tt.want)) {
+			t.Errorf("#%d: got %v; want %v", i, p, tt.want)
+		}
+	}
+}
+
+func TestIsAggregated(t *testing.T) {
+	for i, tt := range []struct {
+		in   []string
+		want bool
+	}{
+		{[]string{"192.0.2.0/24", "192.0.3.0/24"}, false},
+		{[]string{"192.0.2.0/23"}, true},
+		{[]string{"192.0.3.0/24", "192.0.1.0/24"}, true},
+		{[]string{"192.0.2.0/24", "192.0.2.0/24"}, false},
+		{nil, true},
+
+		{[]string{"2001:db8::/33", "2001:db8:8000::/33"}, false},
+		{[]string{"2001:db8::/32", "2001:db9::/48"}, true},
+
+		{[]string{"::/0", "10.0.0.0/8"}, true},
+		{[]string{"10.0.0.0/8", "::/0"}, true},
+		{[]string{"2001:db8::/32", "10.0.0.0/8", "2001:db8::/48"}, false},
+	} {
+		if ok := ipaddr.IsAggregated(toPrefixes(tt.in)); ok != tt.want {
+			t.Errorf("#%d: got %v; want %v", i, ok, tt.want)
+		}
+	}
+}
+
+func TestIsSubnetOf(t *testing.T) {
+	for i, tt := range []struct {
+		child, parent string
+		want          bool
+	}{
+		{"10.1.0.0/16", "10.0.0.0/8", true},
+		{"10.0.0.0/8", "10.0.0.0/8", false},
+		{"10.0.0.0/8", "10.1.0.0/16", false},
+		{"11.0.0.0/16", "10.0.0.0/8", false},
+		{"10.0.0.1/32", "0.0.0.0/0", true},
+
+		{"2001:db8:1::/48", "2001:db8::/32", true},
+		{"2001:db8::/32", "2001:db8::/32", false},
+		{"10.0.0.0/8", "::/0", false},
+		{"2001:db8::/32", "0.0.0.0/0", false},
+	} {
+		child, parent := toPrefix(tt.child), toPrefix(tt.parent)
+		if ok := ipaddr.IsSubnetOf(child, parent); ok != tt.want {
+			t.Errorf("#%d: got %v; want %v", i, ok, tt.want)
+		}
+	}
+}
+
+func TestIsSubset(t *testing.T) {
+	for i, tt := range []struct {
+		a, b []string
+		want bool
+	}{
+		{[]string{"10.1.0.0/16"}, []string{"10.0.0.0/8"}, true},
+		{[]string{"10.0.0.0/8"}, []string{"10.1.0.0/16"}, false},
+		{[]string{"10.0.0.0/8"}, []string{"10.0.0.0/8"}, true},
+		{[]string{"10.0.0.0/8"}, []string{"10.0.0.0/9", "10.128.0.0/10", "10.192.0.0/10"}, true},
+		{[]string{"10.0.0.0/8"}, []string{"10.0.0.0/9", "10.192.0.0/10"}, false},
+		{[]string{"10.1.0.0/16", "192.0.2.0/24"}, []string{"10.0.0.0/8"}, false},
+		{[]string{"10.1.0.0/16", "2001:db8::/32"}, []string{"10.0.0.0/8", "::/0"}, true},
+		{[]string{"10.1.0.0/16"}, []string{"::/0"}, false},
+		{nil, []string{"10.0.0.0/8"}, true},
+		{[]string{"10.0.0.0/8"}, nil, false},
+	} {
+		if ok := ipaddr.IsSubset(toPrefixes(tt.a), toPrefixes(tt.b)); ok != tt.want {
+			t.Errorf("#%d: got %v; want %v", i, ok, tt.want)
+		}
+	}
+}
+
+func TestLargestExcluding(t *testing.T) {
+	for i, tt := range []struct {
+		include, exclude net.IP
+		want             string
+	}{
+		{net.ParseIP("192.168.1.1"), net.ParseIP("192.168.1.5"), "192.168.1.0/30"},
+		{net.ParseIP("192.168.1.5"), net.ParseIP("192.168.1.1"), "192.168.1.4/30"},
+		{net.ParseIP("192.168.1.0"), net.ParseIP("192.168.1.1"), "192.168.1.0/32"},
+		{net.ParseIP("10.0.0.1"), net.ParseIP("192.168.1.1"), "0.0.0.0/1"},
+		{net.ParseIP("192.168.1.1"), net.ParseIP("192.168.1.1"), ""},
+		{net.ParseIP("192.168.1.1"), net.ParseIP("2001:db8::1"), ""},
+
+		{net.ParseIP("2001:db8::1"), net.ParseIP("2001:db8:0:1::1"), "2001:db8::/64"},
+		{net.ParseIP("2001:db8::1"), net.ParseIP("8000::"), "::/1"},
+	} {
+		p := ipaddr.LargestExcluding(tt.include, tt.exclude)
+		if !reflect.DeepEqual(p, toPrefix(tt.want)) {
+			t.Errorf("#%d: got %v; want %v", i, p, tt.want)
+		}
+		if p != nil && (!p.IPNet.Contains(tt.include) || p.IPNet.Contains(tt.exclude)) {
+			t.Errorf("#%d: %v must contain %v but not %v", i, p, tt.include, tt.exclude)
+		}
+	}
+}
+
+func TestMergeBenefit(t *testing.T) {
+	for i, tt := range []struct {
+		a, b []string
+		want int
+	}{
+		{[]string{"192.0.2.0/25"}, []string{"192.0.2.128/25"}, 1},
+		{[]string{"10.0.0.0/24", "10.0.2.0/24"}, []string{"10.0.1.0/24", "10.0.3.0/24"}, 3},
+		{[]string{"10.0.0.0/8"}, []string{"10.1.0.0/16"}, 1},
+		{[]string{"10.0.0.0/8"}, []string{"192.0.2.0/24"}, 0},
+		{[]string{"192.0.2.0/25", "192.0.2.128/25"}, nil, 1},
+		{nil, nil, 0},
+
+		{[]string{"2001:db8::/33"}, []string{"2001:db8:8000::/33", "10.0.0.0/8"}, 1},
+	} {
+		if n := ipaddr.MergeBenefit(toPrefixes(tt.a), toPrefixes(tt.b)); n != tt.want {
+			t.Errorf("#%d: got %v; want %v", i, n, tt.want)
+		}
+	}
+}
+
+func TestMergePair(t *testing.T) {
+	for i, tt := range []struct {
+		a, b string
+		want string
+	}{
+		{"192.0.2.0/24", "192.0.3.0/24", "192.0.2.0/23"},
+		{"192.0.3.0/24", "192.0.2.0/24", "192.0.2.0/23"},
+		{"0.0.0.0/1", "128.0.0.0/1", "0.0.0.0/0"},
+		{"192.0.3.0/24", "192.0.4.0/24", ""},
+		{"192.0.2.0/24", "192.0.2.0/24", ""},
+		{"192.0.2.0/24", "192.0.3.0/25", ""},
+		{"0.0.0.0/0", "0.0.0.0/0", ""},
+
+		{"2001:db8::/33", "2001:db8:8000::/33", "2001:db8::/32"},
+		{"2001:db8::/128", "2001:db8::1/128", "2001:db8::/127"},
+		{"2001:db8::1/128", "2001:db8::2/128", ""},
+
+		{"0.0.0.0/1", "::/1", ""},
+	} {
+		a, b := toPrefix(tt.a), toPrefix(tt.b)
+		p, ok := ipaddr.MergePair(a, b)
+		if tt.want == "" {
+			if ok {
+				t.Errorf("#%d: got %v; want false", i, p)
+			}
+			continue
+		}
+		if !ok || !p.Equal(toPrefix(tt.want)) {
+			t.Errorf("#%d: got %v, %v; want %v, true", i, p, ok, tt.want)
+		}
+	}
+}
+
+func TestNextPrefixAfter(t *testing.T) {
+	ps := toPrefixes([]string{"192.0.2.128/25", "10.0.0.0/8", "192.0.2.0/26", "2001:db8:1::/48", "2001:db8::/48"})
+	for i, tt := range []struct {
+		in   net.IP
+		want *ipaddr.Prefix
+	}{
+		{net.ParseIP("0.0.0.0"), toPrefix("10.0.0.0/8")},
+		{net.ParseIP("10.0.0.0"), toPrefix("10.0.0.0/8")},
+		{net.ParseIP("10.0.0.1"), toPrefix("192.0.2.0/26")},
+		{net.ParseIP("192.0.2.100"), toPrefix("192.0.2.128/25")},
+		{net.ParseIP("192.0.2.129"), nil},
+
+		{net.ParseIP("::"), toPrefix("2001:db8::/48")},
+		{net.ParseIP("2001:db8:0:1::"), toPrefix("2001:db8:1::/48")},
+		{net.ParseIP("2001:db8:1::1"), nil},
+	} {
+		if p := ipaddr.NextPrefixAfter(tt.in, ps); !reflect.DeepEqual(p, tt.want) {
+			t.Errorf("#%d: got %v; want %v", i, p, tt.want)
+		}
+	}
+}
+
+func TestOverlaps(t *testing.T) {
+	for i, tt := range []struct {
+		a, b string
+		want bool
+	}{
+		{"192.0.2.0/24", "192.0.2.0/24", true},
+		{"192.0.2.0/24", "192.0.2.128/25", true},
+		{"192.0.2.128/25", "192.0.2.0/24", true},
+		{"192.0.2.0/25", "192.0.2.128/25", false},
+
+		{"2001:db8::/32", "2001:db8:f001::/48", true},
+		{"2001:db8::/32", "2001:db9::/32", false},
+
+		{"0.0.0.0/0", "::/0", false},
+		{"::/0", "192.0.2.0/24", false},
+	} {
+		a, b := toPrefix(tt.a), toPrefix(tt.b)
+		if out := ipaddr.Overlaps(a, b); out != tt.want {
+			t.Errorf("#%d: got %v; want %v", i, out, tt.want)
+		}
+	}
+	if ipaddr.Overlaps(nil, toPrefix("192.0.2.0/24")) {
+		t.Error("should be false")
+	}
+}
+
+func TestParents(t *testing.T) {
+	for i, tt := range []struct {
+		in   []string
+		want []int
+	}{
+		{
+			[]string{"10.1.2.0/24", "10.0.0.0/8", "192.0.2.0/24", "10.1.0.0/16", "10.1.3.0/24", "10.2.0.0/16"},
+			[]int{3, -1, -1, 1, 3, 1},
+		},
+		{
+			[]string{"192.0.2.0/25", "192.0.2.0/24", "192.0.2.0/24", "192.0.2.128/25"},
+			[]int{1, -1, -1, 1},
+		},
+		{
+			[]string{"::/0", "10.0.0.0/8", "2001:db8::/32", "10.0.0.0/16", "2001:db8::/48"},
+			[]int{-1, -1, 0, 1, 2},
+		},
+		{
+			nil,
+			[]int{},
+		},
+	} {
+		if out := ipaddr.Parents(toPrefixes(tt.in)); !reflect.DeepEqual(out, tt.want) {
+			t.Errorf("#%d: got %v; want %v", i, out, tt.want)
+		}
+	}
+}
+
+func TestPrefixFromBounds(t *testing.T) {
+	for i, tt := range []struct {
+		first, last net.IP
+		want        *ipaddr.Prefix
+		ok          bool
+	}{
+		{net.ParseIP("192.168.0.0"), net.ParseIP("192.168.0.255"), toPrefix("192.168.0.0/24"), true},
+		{net.ParseIP("192.168.0.1"), net.ParseIP("192.168.0.1"), toPrefix("192.168.0.1/32"), true},
+		{net.ParseIP("0.0.0.0"), net.ParseIP("255.255.255.255"), toPrefix("0.0.0.0/0"), true},
+		{net.ParseIP("192.168.0.1"), net.ParseIP("192.168.0.255"), nil, false},
+		{net.ParseIP("192.168.0.0"), net.ParseIP("192.168.1.127"), nil, false},
+		{net.ParseIP("192.168.0.255"), net.ParseIP("192.168.0.0"), nil, false},
+		{net.ParseIP("192.168.0.0"), net.ParseIP("2001:db8::"), nil, false},
+
+		{net.ParseIP("2001:db8::"), net.ParseIP("2001:db8:ffff:ffff:ffff:ffff:ffff:ffff"), toPrefix("2001:db8::/32"), true},
+		{net.ParseIP("2001:db8::"), net.ParseIP("2001:db8::ffff:ffff:ffff:ffff"), toPrefix("2001:db8::/64"), true},
+		{net.ParseIP("::"), net.ParseIP("ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff"), toPrefix("::/0"), true},
+		{net.ParseIP("2001:db8::1"), net.ParseIP("2001:db8::2"), nil, false},
+	} {
+		p, ok := ipaddr.PrefixFromBounds(tt.first, tt.last)
+		if ok != tt.ok || !reflect.DeepEqual(p, tt.want) {
+			t.Errorf("#%d: got %v, %v; want %v, %v", i, p, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestPrefixesFromBitmap(t *testing.T) {
+	for i, tt := range []struct {
+		base   string
+		bitmap []byte
+		want   []string
+	}{
+		{"192.0.2.0/24", []byte{0x00, 0xff, 0xff, 0x00}, []string{"192.0.2.8/29", "192.0.2.16/29"}},
+		{"192.0.2.0/24", []byte{0x0f, 0xff}, []string{"192.0.2.4/30", "192.0.2.8/29"}},
+		{"192.0.2.0/24", []byte{0x80, 0x01}, []string{"192.0.2.0/32", "192.0.2.15/32"}},
+		{"192.0.2.0/30", []byte{0xff}, []string{"192.0.2.0/30"}},
+		{"192.0.2.0/24", []byte{0x00}, nil},
+		{"192.0.2.0/24", nil, nil},
+		{"10.0.0.0/7", []byte{0xff}, nil},
+
+		{"2001:db8::/120", []byte{0x00, 0x00, 0xff, 0xff}, []string{"2001:db8::10/124"}},
+	} {
+		if ps := ipaddr.PrefixesFromBitmap(toPrefix(tt.base), tt.bitmap); !reflect.DeepEqual(ps, toPrefixes(tt.want)) {
+			t.Errorf("#%d: got %v; want %v", i, ps, tt.want)
+		}
+	}
+
+	bitmap := make([]byte, 1<<13)
+	for i := range bitmap {
+		bitmap[i] = 0xff
+	}
+	ps := ipaddr.PrefixesFromBitmap(toPrefix("10.0.0.0/16"), bitmap)
+	if want := toPrefixes([]string{"10.0.0.0/16"}); !reflect.DeepEqual(ps, want) {
+		t.Errorf("got %v; want %v", ps, want)
+	}
+}
+
+func TestPresentLengths(t *testing.T) {
+	for i, tt := range []struct {
+		in         []string
+		ipv4, ipv6 []int
+	}{
+		{
+			[]string{"192.0.2.0/24", "10.0.0.0/8", "172.16.0.0/16", "10.1.0.0/16", "198.51.100.0/24"},
+			[]int{8, 16, 24},
+			nil,
+		},
+		{
+			[]string{"0.0.0.0/0", "192.0.2.1/32", "2001:db8::/32", "::/0", "2001:db8::1/128", "2001:db8::/48"},
+			[]int{0, 32},
+			[]int{0, 32, 48, 128},
+		},
+		{nil, nil, nil},
+	} {
+		ipv4, ipv6 := ipaddr.PresentLengths(toPrefixes(tt.in))
+		if !reflect.DeepEqual(ipv4, tt.ipv4) || !reflect.DeepEqual(ipv6, tt.ipv6) {
+			t.Errorf("#%d: got %v, %v; want %v, %v", i, ipv4, ipv6, tt.ipv4, tt.ipv6)
+		}
+	}
+}
+
+func TestReverseZones(t *testing.T) {
+	for i, tt := range []struct {
+		in   []string
+		want []string
+	}{
+		{
+			[]string{"192.0.3.0/24", "192.0.2.0/24", "192.0.3.128/25"},
+			[]string{"2.0.192.in-addr.arpa", "3.0.192.in-addr.arpa"},
+		},
+		{
+			[]string{"198.51.100.0/23", "192.0.2.0/26"},
+			[]string{"2.0.192.in-addr.arpa", "100.51.198.in-addr.arpa", "101.51.198.in-addr.arpa"},
+		},
+		{
+			[]string{"10.1.2.0/24", "10.0.0.0/8"},
+			[]string{"10.in-addr.arpa"},
+		},
+		{
+			[]string{"0.0.0.0/0", "10.0.0.0/8"},
+			[]string{"in-addr.arpa"},
+		},
+		{
+			[]string{"192.0.2.1/32", "192.0.2.128/25"},
+			[]string{"2.0.192.in-addr.arpa"},
+		},
+
+		{
+			[]string{"2001:db8::/32", "2001:db8:1::/48", "192.0.2.0/24"},
+			[]string{"2.0.192.in-addr.arpa", "8.b.d.0.1.0.0.2.ip6.arpa"},
+		},
+		{
+			[]string{"2001:db8::/31"},
+			[]string{"8.b.d.0.1.0.0.2.ip6.arpa", "9.b.d.0.1.0.0.2.ip6.arpa"},
+		},
+		{
+			[]string{"2001:db8::1/128"},
+			[]string{"0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2.ip6.arpa"},
+		},
+		{nil, nil},
+	} {
+		if zones := ipaddr.ReverseZones(toPrefixes(tt.in)); !reflect.DeepEqual(zones, tt.want) {
+			t.Errorf("#%d: got %v; want %v", i, zones, tt.want)
+		}
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	for i, tt := range []struct {
+		first, last string
+		want        []string
+	}{
+		// IPv4 prefixes
+		{
+			"192.0.2.0", "192.0.2.255",
+			[]string{
+				"192.0.2.0/24",
+			},
+		},
+		{
+			"1.2.3.4", "5.6.7.8",
+			[]string{
+				"1.2.3.4/30", "1.2.3.8/29", "1.2.3.16/28", "1.2.3.32/27",
+				"1.2.3.64/26", "1.2.3.128/25", "1.2.4.0/22", "1.2.8.0/21",
+				"1.2.16.0/20", "1.2.32.0/19", "1.2.64.0/18", "1.2.128.0/17",
+				"1.3.0.0/16", "1.4.0.0/14", "1.8.0.0/13", "1.16.0.0/12",
+				"1.32.0.0/11", "1.64.0.0/10", "1.128.0.0/9", "2.0.0.0/7",
+				"4.0.0.0/8", "5.0.0.0/14", "5.4.0.0/15", "5.6.0.0/22",
+				"5.6.4.0/23", "5.6.6.0/24", "5.6.7.0/29", "5.6.7.8/32",
+			},
+		},
+		{
+			"192.0.2.1", "192.0.2.1",
+			[]string{
+				"192.0.2.1/32",
+			},
+		},
+		{
+			"0.0.0.0", "0.0.0.0",
+			[]string{
+				"0.0.0.0/32",
+			},
+		},
+		{
+			"255.255.255.255", "255.255.255.255",
+			[]string{
+				"255.255.255.255/32",
+			},
+		},
+		{
+			"192.0.2.1", "192.0.2.0",
+			nil,
+		},
+		{
+			"0.0.0.0", "255.255.255.255",
+			[]string{
+				"0.0.0.0/0",
+			},
+		},
+		{
+			"0.0.0.1", "255.255.255.254",
+			[]string{
+				"0.0.0.1/32", "0.0.0.2/31", "0.0.0.4/30", "0.0.0.8/29",
+				"0.0.0.16/28", "0.0.0.32/27", "0.0.0.64/26", "0.0.0.128/25",
+				"0.0.1.0/24", "0.0.2.0/23", "0.0.4.0/22", "0.0.8.0/21",
+				"0.0.16.0/20", "0.0.32.0/19", "0.0.64.0/18", "0.0.128.0/17",
+				"0.1.0.0/16", "0.2.0.0/15", "0.4.0.0/14", "0.8.0.0/13",
+				"0.16.0.0/12", "0.32.0.0/11", "0.64.0.0/10", "0.128.0.0/9",
+				"1.0.0.0/8", "2.0.0.0/7", "4.0.0.0/6", "8.0.0.0/5",
+				"16.0.0.0/4", "32.0.0.0/3", "64.0.0.0/2", "128.0.0.0/2",
+				"192.0.0.0/3", "224.0.0.0/4", "240.0.0.0/5", "248.0.0.0/6",
+				"252.0.0.0/7", "254.0.0.0/8", "255.0.0.0/9", "255.128.0.0/10",
+				"255.192.0.0/11", "255.224.0.0/12", "255.240.0.0/13", "255.248.0.0/14",
+				"255.252.0.0/15", "255.254.0.0/16", "255.255.0.0/17", "255.255.128.0/18",
+				"255.255.192.0/19", "255.255.224.0/20", "255.255.240.0/21", "255.255.248.0/22",
+				"255.255.252.0/23", "255.255.254.0/24", "255.255.255.0/25", "255.255.255.128/26",
+				"255.255.255.192/27", "255.255.255.224/28", "255.255.255.240/29", "255.255.255.248/30",
+				"255.255.255.252/31", "255.255.255.254/32",
+			},
+		},
+
+		// IPv6 prefixes
+		{
+			"2001:db8:1::", "2001:db8:2::",
+			[]string{
+				"2001:db8:1::/48",
+				"2001:db8:2::/128",
+			},
+		},
+		{
+			"2001:db8::1", "2001:db8::1",
+			[]string{
+				"2001:db8::1/128",
+			},
+		},
+		{
+			"::", "::",
+			[]string{
+				"::/128",
+			},
+		},
+		{
+			"2001:db8::1", "2001:db8::",
+			nil,
+		},
+		{
+			"ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff", "ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff",
+			[]string{
+				"ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff/128",
+			},
+		},
+		{
+			"::", "ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff",
+			[]string{
+				"::/0",
+			},
+		},
+		{
+			"::1", "ffff:ffff:ffff:ffff:ffff:ffff:ffff:fffe",
+			[]string{
+				"::1/128", "::2/127", "::4/126", "::8/125",
+				"::10/124", "::20/123", "::40/122", "::80/121",
+				"::100/120", "::200/119", "::400/118", "::800/117",
+				"::1000/116", "::2000/115", "::4000/114", "::8000/113",
+				"::1:0/112", "::2:0/111", "::4:0/110", "::8:0/109",
+				"::10:0/108", "::20:0/107", "::40:0/106", "::80:0/105",
+				"::100:0/104", "::200:0/103", "::400:0/102", "::800:0/101",
+				"::1000:0/100", "::2000:0/99", "::4000:0/98", "::8000:0/97",
+				"::1:0:0/96", "::2:0:0/95", "::4:0:0/94", "::8:0:0/93",
+				"::10:0:0/92", "::20:0:0/91", "::40:0:0/90", "::80:0:0/89",
+				"::100:0:0/88", "::200:0:0/87", "::400:0:0/86", "::800:0:0/85",
+				"::1000:0:0/84", "::2000:0:0/83", "::4000:0:0/82", "::8000:0:0/81",
+				"::1:0:0:0/80", "::2:0:0:0/79", "::4:0:0:0/78", "::8:0:0:0/77",
+				"::10:0:0:0/76", "::20:0:0:0/75", "::40:0:0:0/74", "::80:0:0:0/73",
+				"::100:0:0:0/72", "::200:0:0:0/71", "::400:0:0:0/70", "::800:0:0:0/69",
+				"::1000:0:0:0/68", "::2000:0:0:0/67", "::4000:0:0:0/66", "::8000:0:0:0/65",
+				"0:0:0:1::/64", "0:0:0:2::/63", "0:0:0:4::/62", "0:0:0:8::/61",
+				"0:0:0:10::/60", "0:0:0:20::/59", "0:0:0:40::/58", "0:0:0:80::/57",
+				"0:0:0:100::/56", "0:0:0:200::/55", "0:0:0:400::/54", "0:0:0:800::/53",
+				"0:0:0:1000::/52", "0:0:0:2000::/51", "0:0:0:4000::/50", "0:0:0:8000::/49",
+				"0:0:1::/48", "0:0:2::/47", "0:0:4::/46", "0:0:8::/45",
+				"0:0:10::/44", "0:0:20::/43", "0:0:40::/42", "0:0:80::/41",
+				"0:0:100::/40", "0:0:200::/39", "0:0:400::/38", "0:0:800::/37",
+				"0:0:1000::/36", "0:0:2000::/35", "0:0:4000::/34", "0:0:8000::/33",
+				"0:1::/32", "0:2::/31", "0:4::/30", "0:8::/29",
+				"0:10::/28", "0:20::/27", "0:40::/26", "0:80::/25",
+				"0:100::/24", "0:200::/23", "0:400::/22", "0:800::/21",
+				"0:1000::/20", "0:2000::/19", "0:4000::/18", "0:8000::/17",
+				"1::/16", "2::/15", "4::/14", "8::/13",
+				"10::/12", "20::/11", "40::/10", "80::/9",
+				"ffff:ffff:ffff:ffff:ffff:ffff:ffff:ff00/121", "ffff:ffff:ffff:ffff:ffff:ffff:ffff:ff80/122", "ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffc0/123", "ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffe0/124",
+				"ffff:ffff:ffff:ffff:ffff:ffff:ffff:fff0/125", "ffff:ffff:ffff:ffff:ffff:ffff:ffff:fff8/126", "ffff:ffff:ffff:ffff:ffff:ffff:ffff:fffc/127", "ffff:ffff:ffff:ffff:ffff:ffff:ffff:fffe/128",
+				"ffff:ffff:ffff:ffff:ffff:ffff:ffff::/113", "ffff:ffff:ffff:ffff:ffff:ffff:ffff:8000/114", "ffff:ffff:ffff:ffff:ffff:ffff:ffff:c000/115", "ffff:ffff:ffff:ffff:ffff:ffff:ffff:e000/116",
+				"ffff:ffff:ffff:ffff:ffff:ffff:ffff:f000/117", "ffff:ffff:ffff:ffff:ffff:ffff:ffff:f800/118", "ffff:ffff:ffff:ffff:ffff:ffff:ffff:fc00/119", "ffff:ffff:ffff:ffff:ffff:ffff:ffff:fe00/120",
+				"ffff:ffff:ffff:ffff:ffff:ffff:ff00::/105", "ffff:ffff:ffff:ffff:ffff:ffff:ff80::/106", "ffff:ffff:ffff:ffff:ffff:ffff:ffc0::/107", "ffff:ffff:ffff:ffff:ffff:ffff:ffe0::/108",
+				"ffff:ffff:ffff:ffff:ffff:ffff:fff0::/109", "ffff:ffff:ffff:ffff:ffff:ffff:fff8::/110", "ffff:ffff:ffff:ffff:ffff:ffff:fffc::/111", "ffff:ffff:ffff:ffff:ffff:ffff:fffe::/112",
+				"ffff:ffff:ffff:ffff:ffff:ffff::/97", "ffff:ffff:ffff:ffff:ffff:ffff:8000::/98", "ffff:ffff:ffff:ffff:ffff:ffff:c000::/99", "ffff:ffff:ffff:ffff:ffff:ffff:e000::/100",
+				"ffff:ffff:ffff:ffff:ffff:ffff:f000::/101", "ffff:ffff:ffff:ffff:ffff:ffff:f800::/102", "ffff:ffff:ffff:ffff:ffff:ffff:fc00::/103", "ffff:ffff:ffff:ffff:ffff:ffff:fe00::/104",
+				"ffff:ffff:ffff:ffff:ffff:ff00::/89", "ffff:ffff:ffff:ffff:ffff:ff80::/90", "ffff:ffff:ffff:ffff:ffff:ffc0::/91", "ffff:ffff:ffff:ffff:ffff:ffe0::/92",
+				"ffff:ffff:ffff:ffff:ffff:fff0::/93", "ffff:ffff:ffff:ffff:ffff:fff8::/94", "ffff:ffff:ffff:ffff:ffff:fffc::/95", "ffff:ffff:ffff:ffff:ffff:fffe::/96",
+				"ffff:ffff:ffff:ffff:ffff::/81", "ffff:ffff:ffff:ffff:ffff:8000::/82", "ffff:ffff:ffff:ffff:ffff:c000::/83", "ffff:ffff:ffff:ffff:ffff:e000::/84",
+				"ffff:ffff:ffff:ffff:ffff:f000::/85", "ffff:ffff:ffff:ffff:ffff:f800::/86", "ffff:ffff:ffff:ffff:ffff:fc00::/87", "ffff:ffff:ffff:ffff:ffff:fe00::/88",
+				"ffff:ffff:ffff:ffff:ff00::/73", "ffff:ffff:ffff:ffff:ff80::/74", "ffff:ffff:ffff:ffff:ffc0::/75", "ffff:ffff:ffff:ffff:ffe0::/76",
+				"ffff:ffff:ffff:ffff:fff0::/77", "ffff:ffff:ffff:ffff:fff8::/78", "ffff:ffff:ffff:ffff:fffc::/79", "ffff:ffff:ffff:ffff:fffe::/80",
+				"ffff:ffff:ffff:ffff::/65", "ffff:ffff:ffff:ffff:8000::/66", "ffff:ffff:ffff:ffff:c000::/67", "ffff:ffff:ffff:ffff:e000::/68",
+				"ffff:ffff:ffff:ffff:f000::/69", "ffff:ffff:ffff:ffff:f800::/70", "ffff:ffff:ffff:ffff:fc00::/71", "ffff:ffff:ffff:ffff:fe00::/72",
+				"ffff:ffff:ffff:ff00::/57", "ffff:ffff:ffff:ff80::/58", "ffff:ffff:ffff:ffc0::/59", "ffff:ffff:ffff:ffe0::/60",
+				"ffff:ffff:ffff:fff0::/61", "ffff:ffff:ffff:fff8::/62", "ffff:ffff:ffff:fffc::/63", "ffff:ffff:ffff:fffe::/64",
+				"ffff:ffff:ffff::/49", "ffff:ffff:ffff:8000::/50", "ffff:ffff:ffff:c000::/51", "ffff:ffff:ffff:e000::/52",
+				"ffff:ffff:ffff:f000::/53", "ffff:ffff:ffff:f800::/54", "ffff:ffff:ffff:fc00::/55", "ffff:ffff:ffff:fe00::/56",
+				"ffff:ffff:ff00::/41", "ffff:ffff:ff80::/42", "ffff:ffff:ffc0::/43", "ffff:ffff:ffe0::/44",
+				"ffff:ffff:fff0::/45", "ffff:ffff:fff8::/46", "ffff:ffff:fffc::/47", "ffff:ffff:fffe::/48",
+				"ffff:ffff::/33", "ffff:ffff:8000::/34", "ffff:ffff:c000::/35", "ffff:ffff:e000::/36",
+				"ffff:ffff:f000::/37", "ffff:ffff:f800::/38", "ffff:ffff:fc00::/39", "ffff:ffff:fe00::/40",
+				"ffff:ff00::/25", "ffff:ff80::/26", "ffff:ffc0::/27", "ffff:ffe0::/28",
+				"ffff:fff0::/29", "ffff:fff8::/30", "ffff:fffc::/31", "ffff:fffe::/32",
+				"ffff::/17", "ffff:8000::/18", "ffff:c000::/19", "ffff:e000::/20",
+				"ffff:f000::/21", "ffff:f800::/22", "ffff:fc00::/23", "ffff:fe00::/24",
+				"ff00::/9", "ff80::/10", "ffc0::/11", "ffe0::/12",
+				"fff0::/13", "fff8::/14", "fffc::/15", "fffe::/16",
+				"100::/8", "200::/7", "400::/6", "800::/5",
+				"1000::/4", "2000::/3", "4000::/2", "8000::/2",
+				"c000::/3", "e000::/4", "f000::/5", "f800::/6",
+				"fc00::/7", "fe00::/8",
+			},
+		},
+	} {
+		fip := net.ParseIP(tt.first)
+		if fip == nil {
+			t.Fatalf("non-IP address: %s", tt.first)
+		}
+		lip := net.ParseIP(tt.last)
+		if lip == nil {
+			t.Fatalf("non-IP address: %s", tt.last)
+		}
+		want := toPrefixes(tt.want)
+		sort.Sort(byAscending(want))
+		out := ipaddr.Summarize(fip, lip)
+		if !reflect.DeepEqual(out, want) {
+			t.Errorf("#%d: got %v; want %v", i, out, want)
+		}
+	}
+
+	ipaddr.Summarize(nil, nil)
+}
+
+func TestSupernet(t *testing.T) {
+	for i, tt := range []struct {
+		in   []string
+		want string
+	}{
+		// IPv4 prefixes
+		{
+			[]string{
+				"192.0.2.0/25", "192.0.2.128/25",
+			},
+			"192.0.2.0/24",
+		},
+		{
+			[]string{
+				"192.0.2.0/27", "192.0.2.32/27", "192.0.2.64/27", "192.0.2.96/27",
+				"192.0.2.128/27", "192.0.2.160/27", "192.0.2.192/27", "192.0.2.224/27",
+			},
+			"192.0.2.0/24",
+		},
+		{
+			[]string{
+				"192.0.2.0/28", "192.0.2.16/28", "192.0.2.32/28", "192.0.2.48/28",
+				"192.0.2.64/28", "192.0.2.80/28", "192.0.2.96/28", "192.0.2.112/28",
+				"192.0.2.128/28", "192.0.2.144/28", "192.0.2.160/28", "192.0.2.176/28",
+				"192.0.2.192/28", "192.0.2.208/28", "192.0.2.224/28", "192.0.2.240/28",
+			},
+			"192.0.2.0/24",
+		},
+		{
+			[]string{
+				"10.40.101.1/32", "10.40.102.1/32", "11.40.103.1/32",
 			},
 			"10.0.0.0/7",
 		},
@@ -540,55 +1712,313 @@ func TestSupernet(t *testing.T) {
 			"2013:db8::/46",
 		},
 
-		// IPv6 prefixes, no supernet
-		{
-			[]string{
-				"8001:db8:1::/34",
-				"2013:db8:2::/32",
-			},
-			"",
-		},
-		{
-			[]string{
-				"2001:db8::1/64",
-				"192.168.0.1/24",
-				"8001:db8::1/64",
-			},
-			"",
-		},
+		// IPv6 prefixes, no supernet
+		{
+			[]string{
+				"8001:db8:1::/34",
+				"2013:db8:2::/32",
+			},
+			"",
+		},
+		{
+			[]string{
+				"2001:db8::1/64",
+				"192.168.0.1/24",
+				"8001:db8::1/64",
+			},
+			"",
+		},
+
+		// Mixed prefixes
+		{
+			[]string{
+				"192.0.2.0/25",
+				"2001:db8::/64",
+				"192.0.2.128/25",
+			},
+			"192.0.2.0/24",
+		},
+
+		// Mixed prefixes, no supernet
+		{
+			[]string{
+				"0.0.0.0/0",
+				"192.0.2.1/24",
+				"2001:db8::1/64",
+			},
+			"",
+		},
+	} {
+		in, orig := toPrefixes(tt.in), toPrefixes(tt.in)
+		want := toPrefix(tt.want)
+		out := ipaddr.Supernet(in)
+		if !reflect.DeepEqual(out, want) {
+			t.Errorf("#%d: got %v; want %v", i, out, want)
+		}
+		if !reflect.DeepEqual(in, orig) {
+			t.Errorf("#%d: %v is corrupted; want %v", i, in, orig)
+		}
+	}
+
+	ipaddr.Supernet(nil)
+}
+
+func TestTimeline(t *testing.T) {
+	for i, tt := range []struct {
+		container string
+		allocated []string
+		want      []ipaddr.TimelineSegment
+	}{
+		{
+			"192.0.2.0/24", []string{"192.0.2.64/26", "192.0.2.192/27", "192.0.2.64/27", "198.51.100.0/24"},
+			[]ipaddr.TimelineSegment{
+				{Prefix: *toPrefix("192.0.2.0/26"), Free: true},
+				{Prefix: *toPrefix("192.0.2.64/26")},
+				{Prefix: *toPrefix("192.0.2.128/26"), Free: true},
+				{Prefix: *toPrefix("192.0.2.192/27")},
+				{Prefix: *toPrefix("192.0.2.224/27"), Free: true},
+			},
+		},
+		{
+			"192.0.2.0/24", nil,
+			[]ipaddr.TimelineSegment{{Prefix: *toPrefix("192.0.2.0/24"), Free: true}},
+		},
+		{
+			"192.0.2.0/24", []string{"192.0.0.0/16"},
+			[]ipaddr.TimelineSegment{{Prefix: *toPrefix("192.0.2.0/24")}},
+		},
+
+		{
+			"2001:db8::/32", []string{"2001:db8:8000::/33"},
+			[]ipaddr.TimelineSegment{
+				{Prefix: *toPrefix("2001:db8::/33"), Free: true},
+				{Prefix: *toPrefix("2001:db8:8000::/33")},
+			},
+		},
+	} {
+		segs := ipaddr.Timeline(toPrefix(tt.container), toPrefixes(tt.allocated))
+		if !reflect.DeepEqual(segs, tt.want) {
+			t.Errorf("#%d: got %v; want %v", i, segs, tt.want)
+		}
+	}
+}
+
+func TestVLSM(t *testing.T) {
+	for i, tt := range []struct {
+		in     string
+		nhosts []int
+		want   []string
+	}{
+		{"192.168.0.0/24", []int{120, 60, 30}, []string{"192.168.0.0/25", "192.168.0.128/26", "192.168.0.192/27"}},
+		{"192.168.0.0/24", []int{30, 120, 60}, []string{"192.168.0.192/27", "192.168.0.0/25", "192.168.0.128/26"}},
+		{"192.168.0.0/24", []int{2, 1, 2}, []string{"192.168.0.0/31", "192.168.0.4/32", "192.168.0.2/31"}},
+		{"192.168.0.0/24", []int{254}, []string{"192.168.0.0/24"}},
+		{"192.168.0.0/24", []int{255}, nil},
+		{"192.168.0.0/24", []int{120, 120, 2}, nil},
+		{"192.168.0.0/24", []int{0}, nil},
+
+		{"2001:db8::/64", []int{1 << 16, 255}, []string{"2001:db8::/111", "2001:db8::2:0/120"}},
+		{"2001:db8::/120", []int{256}, nil},
+	} {
+		p := toPrefix(tt.in)
+		ps, err := ipaddr.VLSM(p, tt.nhosts)
+		if tt.want == nil {
+			if err == nil {
+				t.Errorf("#%d: got %v; want an error", i, ps)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("#%d: %v", i, err)
+			continue
+		}
+		if want := toPrefixes(tt.want); !reflect.DeepEqual(ps, want) {
+			t.Errorf("#%d: got %v; want %v", i, ps, want)
+		}
+	}
+
+	for _, n := range []int{0, -1} {
+		if _, err := ipaddr.VLSM(toPrefix("192.168.0.0/24"), []int{120, n}); err == nil || err.Error() != "invalid number of hosts" {
+			t.Errorf("%d hosts: got %v; want invalid number of hosts", n, err)
+		}
+	}
+}
+
+func TestWalkHierarchy(t *testing.T) {
+	type walk struct {
+		p     string
+		depth int
+	}
+	for i, tt := range []struct {
+		in   []string
+		want []walk
+	}{
+		{
+			[]string{"10.1.2.0/24", "2001:db8::/48", "10.0.0.0/8", "10.2.0.0/16", "10.1.0.0/16", "2001:db8::/32", "10.1.2.128/25"},
+			[]walk{
+				{"10.0.0.0/8", 0},
+				{"10.1.0.0/16", 1},
+				{"10.1.2.0/24", 2},
+				{"10.1.2.128/25", 3},
+				{"10.2.0.0/16", 1},
+				{"2001:db8::/32", 0},
+				{"2001:db8::/48", 1},
+			},
+		},
+		{
+			[]string{"192.0.2.0/24", "198.51.100.0/24"},
+			[]walk{
+				{"192.0.2.0/24", 0},
+				{"198.51.100.0/24", 0},
+			},
+		},
+		{
+			nil,
+			nil,
+		},
+	} {
+		var out []walk
+		ipaddr.WalkHierarchy(toPrefixes(tt.in), func(p *ipaddr.Prefix, depth int) {
+			out = append(out, walk{p.String(), depth})
+		})
+		if !reflect.DeepEqual(out, tt.want) {
+			t.Errorf("#%d: got %v; want %v", i, out, tt.want)
+		}
+	}
+}
+
+func TestPrefixAFI(t *testing.T) {
+	for i, tt := range []struct {
+		in   *ipaddr.Prefix
+		afi  uint16
+		name string
+	}{
+		{toPrefix("192.0.2.0/24"), 1, "ipv4"},
+		{toPrefix("0.0.0.0/0"), 1, "ipv4"},
+		{toPrefix("::ffff:192.0.2.0/120"), 1, "ipv4"},
+
+		{toPrefix("2001:db8::/32"), 2, "ipv6"},
+		{toPrefix("::/0"), 2, "ipv6"},
+
+		{&ipaddr.Prefix{}, 0, ""},
+	} {
+		afi, name := tt.in.AFI()
+		if afi != tt.afi || name != tt.name {
+			t.Errorf("#%d: got %v, %v; want %v, %v", i, afi, name, tt.afi, tt.name)
+		}
+	}
+}
+
+func TestPrefixAddrAtFraction(t *testing.T) {
+	for i, tt := range []struct {
+		in   string
+		f    float64
+		want net.IP
+	}{
+		{"192.0.2.0/24", 0.5, net.ParseIP("192.0.2.128")},
+		{"192.0.2.0/24", 0.25, net.ParseIP("192.0.2.64")},
+		{"192.0.2.0/24", 0, net.ParseIP("192.0.2.0")},
+		{"192.0.2.0/24", -1, net.ParseIP("192.0.2.0")},
+		{"192.0.2.0/24", 0.999, net.ParseIP("192.0.2.255")},
+		{"192.0.2.0/24", 1, net.ParseIP("192.0.2.255")},
+		{"192.0.2.1/32", 0.5, net.ParseIP("192.0.2.1")},
+		{"0.0.0.0/0", 0.75, net.ParseIP("192.0.0.0")},
+
+		{"2001:db8::/32", 0.5, net.ParseIP("2001:db8:8000::")},
+		{"2001:db8::/64", 0.125, net.ParseIP("2001:db8::2000:0:0:0")},
+		{"::/0", 1, net.ParseIP("ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff")},
+	} {
+		p := toPrefix(tt.in)
+		if ip := p.AddrAtFraction(tt.f); !ip.Equal(tt.want) {
+			t.Errorf("#%d: got %v; want %v", i, ip, tt.want)
+		}
+	}
+}
+
+func TestPrefixAddrReader(t *testing.T) {
+	for i, tt := range []struct {
+		in    string
+		begin net.IP
+		want  string
+	}{
+		{"192.0.2.0/30", nil, "192.0.2.0\n192.0.2.1\n192.0.2.2\n192.0.2.3\n"},
+		{"192.0.2.0/30", net.ParseIP("192.0.2.2"), "192.0.2.2\n192.0.2.3\n"},
+		{"192.0.2.1/32", nil, "192.0.2.1\n"},
+		{"192.0.2.0/30", net.ParseIP("192.0.2.4"), ""},
+		{"255.255.255.254/31", nil, "255.255.255.254\n255.255.255.255\n"},
+
+		{"2001:db8::/126", nil, "2001:db8::\n2001:db8::1\n2001:db8::2\n2001:db8::3\n"},
+		{"ffff:ffff:ffff:ffff:ffff:ffff:ffff:fffe/127", nil, "ffff:ffff:ffff:ffff:ffff:ffff:ffff:fffe\nffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff\n"},
+	} {
+		p := toPrefix(tt.in)
+		b, err := ioutil.ReadAll(p.AddrReader(tt.begin))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != tt.want {
+			t.Errorf("#%d: got %q; want %q", i, b, tt.want)
+		}
+		b, err = ioutil.ReadAll(iotest.OneByteReader(p.AddrReader(tt.begin)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != tt.want {
+			t.Errorf("#%d: got %q; want %q", i, b, tt.want)
+		}
+	}
+
+	b := make([]byte, 9)
+	if _, err := io.ReadFull(toPrefix("::/0").AddrReader(nil), b); err != nil || string(b) != "::\n::1\n::" {
+		t.Errorf("got %q, %v; want %q", b, err, "::\n::1\n::")
+	}
+}
 
-		// Mixed prefixes
-		{
-			[]string{
-				"192.0.2.0/25",
-				"2001:db8::/64",
-				"192.0.2.128/25",
-			},
-			"192.0.2.0/24",
-		},
+func TestPrefixAppendString(t *testing.T) {
+	for i, p := range []*ipaddr.Prefix{
+		toPrefix("0.0.0.0/0"),
+		toPrefix("192.0.2.0/24"),
+		toPrefix("255.255.255.255/32"),
+		toPrefix("::ffff:192.0.2.0/120"),
 
-		// Mixed prefixes, no supernet
-		{
-			[]string{
-				"0.0.0.0/0",
-				"192.0.2.1/24",
-				"2001:db8::1/64",
-			},
-			"",
-		},
+		toPrefix("::/0"),
+		toPrefix("::1/128"),
+		toPrefix("2001:db8::/32"),
+		toPrefix("2001:db8:0:1:0:0:1:0/127"),
+		toPrefix("2001:0:0:1::1/128"),
+		toPrefix("2001:db8:cafe:babe:1:2:3:4/128"),
+		toPrefix("fe80::1:0:0:0/64"),
+
+		{IPNet: net.IPNet{IP: net.ParseIP("192.0.2.1"), Mask: net.IPMask{0xff, 0x00, 0xff, 0x00}}},
 	} {
-		in, orig := toPrefixes(tt.in), toPrefixes(tt.in)
-		want := toPrefix(tt.want)
-		out := ipaddr.Supernet(in)
-		if !reflect.DeepEqual(out, want) {
+		b := []byte("prefix=")
+		if out, want := string(p.AppendString(b)), "prefix="+p.String(); out != want {
 			t.Errorf("#%d: got %v; want %v", i, out, want)
 		}
-		if !reflect.DeepEqual(in, orig) {
-			t.Errorf("#%d: %v is corrupted; want %v", i, in, orig)
-		}
 	}
+}
 
-	ipaddr.Supernet(nil)
+func TestPrefixBigBounds(t *testing.T) {
+	for i, tt := range []struct {
+		in         string
+		start, end string
+	}{
+		{"0.0.0.0/0", "0", "4294967295"},
+		{"192.0.2.0/24", "3221225984", "3221226239"},
+		{"192.0.2.1/32", "3221225985", "3221225985"},
+
+		{"::/0", "0", "340282366920938463463374607431768211455"},
+		{"2001:db8::/32", "42540766411282592856903984951653826560", "42540766490510755371168322545197776895"},
+	} {
+		p := toPrefix(tt.in)
+		start, end := p.BigBounds()
+		if start.String() != tt.start || end.String() != tt.end {
+			t.Errorf("#%d: got %v, %v; want %v, %v", i, start, end, tt.start, tt.end)
+		}
+		n := new(big.Int).Sub(end, start)
+		if n.Add(n, big.NewInt(1)).Cmp(p.NumNodes()) != 0 {
+			t.Errorf("#%d: got %v; want %v", i, n, p.NumNodes())
+		}
+	}
 }
 
 func TestPrefixBinaryMarshalerUnmarshaler(t *testing.T) {
@@ -644,6 +2074,82 @@ func TestPrefixBinaryMarshalerUnmarshaler(t *testing.T) {
 	}
 }
 
+func TestPrefixCanSplit(t *testing.T) {
+	for i, tt := range []struct {
+		in        string
+		count     int64
+		subnetLen int
+		want      bool
+	}{
+		{"192.0.2.0/24", 8, 27, true},
+		{"192.0.2.0/24", 10, 27, false},
+		{"192.0.2.0/24", 0, 27, true},
+		{"192.0.2.0/24", 1, 24, true},
+		{"192.0.2.0/24", 2, 24, false},
+		{"192.0.2.0/24", 1, 23, false},
+		{"192.0.2.0/24", 1, 33, false},
+		{"192.0.2.0/24", -1, 27, false},
+
+		{"2001:db8::/32", 65536, 48, true},
+		{"2001:db8::/32", 65537, 48, false},
+		{"::/0", 1 << 62, 128, true},
+		{"2001:db8::/64", 1, 129, false},
+	} {
+		p := toPrefix(tt.in)
+		if ok := p.CanSplit(big.NewInt(tt.count), tt.subnetLen); ok != tt.want {
+			t.Errorf("#%d: got %v; want %v", i, ok, tt.want)
+		}
+	}
+}
+
+func TestPrefixContainedBlocks(t *testing.T) {
+	for i, tt := range []struct {
+		in       string
+		blockLen int
+		want     []ipaddr.Prefix
+	}{
+		{"192.0.2.0/24", 26, toPrefixes([]string{"192.0.2.0/26", "192.0.2.64/26", "192.0.2.128/26", "192.0.2.192/26"})},
+		{"192.0.2.0/24", 24, toPrefixes([]string{"192.0.2.0/24"})},
+		{"192.0.2.0/24", 16, nil},
+		{"192.0.2.0/24", 40, nil},
+		{"0.0.0.0/0", 1, toPrefixes([]string{"0.0.0.0/1", "128.0.0.0/1"})},
+
+		{"2001:db8::/46", 48, toPrefixes([]string{"2001:db8::/48", "2001:db8:1::/48", "2001:db8:2::/48", "2001:db8:3::/48"})},
+		{"2001:db8::/32", 16, nil},
+		{"2001:db8::/120", 129, nil},
+	} {
+		p := toPrefix(tt.in)
+		if ps := p.ContainedBlocks(tt.blockLen); !reflect.DeepEqual(ps, tt.want) {
+			t.Errorf("#%d: got %v; want %v", i, ps, tt.want)
+		}
+		var ps []ipaddr.Prefix
+		p.ContainedBlocksFunc(tt.blockLen, func(b *ipaddr.Prefix) bool {
+			ps = append(ps, *b)
+			return true
+		})
+		if !reflect.DeepEqual(ps, tt.want) {
+			t.Errorf("#%d: got %v; want %v", i, ps, tt.want)
+		}
+	}
+
+	n := 0
+	toPrefix("2001:db8::/32").ContainedBlocksFunc(64, func(b *ipaddr.Prefix) bool {
+		n++
+		return n < 3
+	})
+	if n != 3 {
+		t.Errorf("got %v; want 3", n)
+	}
+	n = 0
+	toPrefix("::/0").ContainedBlocksFunc(1, func(b *ipaddr.Prefix) bool {
+		n++
+		return true
+	})
+	if n != 2 {
+		t.Errorf("got %v; want 2", n)
+	}
+}
+
 func TestPrefixIPNetContains(t *testing.T) {
 	for i, tt := range []struct {
 		in   string
@@ -678,47 +2184,445 @@ func TestPrefixContains(t *testing.T) {
 		{toPrefixes([]string{"2001:db8:1::/48", "2001:db8:1::/48"}), false},
 		{toPrefixes([]string{"2001:db8:1::/49", "2001:db8:1::/48"}), false},
 
-		{toPrefixes([]string{"2001:db8:1::/127", "2001:db8:1::/128"}), true},
-		{toPrefixes([]string{"2001:db8:1::/127", "2001:db8:1::/127"}), false},
-		{toPrefixes([]string{"2001:db8:1::/128", "2001:db8:1::/127"}), false},
+		{toPrefixes([]string{"2001:db8:1::/127", "2001:db8:1::/128"}), true},
+		{toPrefixes([]string{"2001:db8:1::/127", "2001:db8:1::/127"}), false},
+		{toPrefixes([]string{"2001:db8:1::/128", "2001:db8:1::/127"}), false},
+
+		{toPrefixes([]string{"192.0.2.1/24", "2001:db8:1::1/64"}), false},
+		{toPrefixes([]string{"2001:db8:1::1/64", "192.0.2.1/24"}), false},
+	} {
+		if ok := tt.in[0].Contains(&tt.in[1]); ok != tt.ok {
+			t.Errorf("#%d: got %v; want %v", i, ok, tt.ok)
+		}
+	}
+}
+
+func TestPrefixContainsPrefix(t *testing.T) {
+	for i, tt := range []struct {
+		in []ipaddr.Prefix
+		ok bool
+	}{
+		{toPrefixes([]string{"192.0.2.0/23", "192.0.2.0/24"}), true},
+		{toPrefixes([]string{"192.0.2.0/24", "192.0.2.0/24"}), true},
+		{toPrefixes([]string{"192.0.2.0/25", "192.0.2.0/24"}), false},
+		{toPrefixes([]string{"192.0.2.0/24", "192.0.3.0/24"}), false},
+		{toPrefixes([]string{"0.0.0.0/0", "255.255.255.255/32"}), true},
+
+		{toPrefixes([]string{"2001:db8:1::/47", "2001:db8:1::/48"}), true},
+		{toPrefixes([]string{"2001:db8:1::/48", "2001:db8:1::/48"}), true},
+		{toPrefixes([]string{"2001:db8:1::/49", "2001:db8:1::/48"}), false},
+		{toPrefixes([]string{"2001:db8::1/128", "2001:db8::1/128"}), true},
+
+		{toPrefixes([]string{"::/0", "192.0.2.0/24"}), false},
+		{toPrefixes([]string{"::/0", "0.0.0.0/0"}), false},
+		{toPrefixes([]string{"0.0.0.0/0", "::/0"}), false},
+		{toPrefixes([]string{"0.0.0.0/0", "2001:db8::/32"}), false},
+	} {
+		if ok := tt.in[0].ContainsPrefix(&tt.in[1]); ok != tt.ok {
+			t.Errorf("#%d: got %v; want %v", i, ok, tt.ok)
+		}
+	}
+}
+
+func TestPrefixEdges(t *testing.T) {
+	for i, tt := range []struct {
+		in          string
+		n           int
+		first, last []net.IP
+	}{
+		{"192.168.0.0/24", 2, []net.IP{net.ParseIP("192.168.0.0"), net.ParseIP("192.168.0.1")}, []net.IP{net.ParseIP("192.168.0.254"), net.ParseIP("192.168.0.255")}},
+		{"192.168.0.0/31", 2, []net.IP{net.ParseIP("192.168.0.0"), net.ParseIP("192.168.0.1")}, nil},
+		{"192.168.0.0/30", 3, []net.IP{net.ParseIP("192.168.0.0"), net.ParseIP("192.168.0.1"), net.ParseIP("192.168.0.2")}, []net.IP{net.ParseIP("192.168.0.3")}},
+		{"192.168.0.1/32", 1, []net.IP{net.ParseIP("192.168.0.1")}, nil},
+		{"192.168.0.0/24", 0, nil, nil},
+
+		{"2001:db8::/32", 2, []net.IP{net.ParseIP("2001:db8::"), net.ParseIP("2001:db8::1")}, []net.IP{net.ParseIP("2001:db8:ffff:ffff:ffff:ffff:ffff:fffe"), net.ParseIP("2001:db8:ffff:ffff:ffff:ffff:ffff:ffff")}},
+		{"::/0", 1, []net.IP{net.ParseIP("::")}, []net.IP{net.ParseIP("ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff")}},
+		{"2001:db8::/127", 2, []net.IP{net.ParseIP("2001:db8::"), net.ParseIP("2001:db8::1")}, nil},
+	} {
+		p := toPrefix(tt.in)
+		first, last := p.Edges(tt.n)
+		if !reflect.DeepEqual(first, tt.first) {
+			t.Errorf("#%d: got %v; want %v", i, first, tt.first)
+		}
+		if !reflect.DeepEqual(last, tt.last) {
+			t.Errorf("#%d: got %v; want %v", i, last, tt.last)
+		}
+	}
+}
+
+func TestPrefixEmbedded4(t *testing.T) {
+	for i, tt := range []struct {
+		in   string
+		want net.IP
+		ok   bool
+	}{
+		{"2002:c0a8:1::/48", net.ParseIP("192.168.0.1"), true},
+		{"2002:c000:204:1::/64", net.ParseIP("192.0.2.4"), true},
+		{"2002:c0a8::/32", nil, false},
+		{"2001:0:4136:e378:8000:63bf:3fff:fdd2/128", net.ParseIP("192.0.2.45"), true},
+		{"2001:0:4136:e378::/64", nil, false},
+		{"::ffff:192.0.2.1/128", net.ParseIP("192.0.2.1"), true},
+		{"::192.0.2.1/128", net.ParseIP("192.0.2.1"), true},
+		{"::1/128", nil, false},
+		{"2001:db8::/32", nil, false},
+		{"192.0.2.1/32", nil, false},
+	} {
+		p := toPrefix(tt.in)
+		ip, ok := p.Embedded4()
+		if ok != tt.ok || !ip.Equal(tt.want) {
+			t.Errorf("#%d: got %v, %v; want %v, %v", i, ip, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestPrefixExclude(t *testing.T) {
+	for i, tt := range []struct {
+		in, excl string
+	}{
+		{"192.0.0.0/16", "192.0.2.0/24"},
+		{"192.0.2.0/24", "192.0.2.0/32"},
+
+		{"2001:db8:f001::/48", "2001:db8:f001:f002::/56"},
+		{"2001:db8:f001:f002::/64", "2001:db8:f001:f002::cafe/128"},
+	} {
+		p, excl := toPrefix(tt.in), toPrefix(tt.excl)
+		ps := p.Exclude(excl)
+		if len(ps) != excl.Len()-p.Len() {
+			for _, p := range ps {
+				t.Logf("subnet: %v", p)
+			}
+			t.Errorf("#%d: got %v; want %v", i, len(ps), excl.Len()-p.Len())
+		}
+		diff, sum := big.NewInt(0), big.NewInt(0)
+		diff.Sub(p.NumNodes(), excl.NumNodes())
+		for _, p := range ps {
+			sum.Add(sum, p.NumNodes())
+		}
+		if diff.String() != sum.String() {
+			for _, p := range ps {
+				t.Logf("subnet: %v", p)
+			}
+			t.Errorf("#%d: got %v; want %v", i, sum.String(), diff.String())
+		}
+	}
+}
+
+func TestPrefixExcludeAsRanges(t *testing.T) {
+	for i, tt := range []struct {
+		in, excl string
+		want     []ipaddr.Range
+	}{
+		{
+			"10.0.0.0/8", "10.1.0.0/16",
+			[]ipaddr.Range{
+				{First: net.ParseIP("10.0.0.0"), Last: net.ParseIP("10.0.255.255")},
+				{First: net.ParseIP("10.2.0.0"), Last: net.ParseIP("10.255.255.255")},
+			},
+		},
+		{
+			"10.0.0.0/8", "10.0.0.0/9",
+			[]ipaddr.Range{
+				{First: net.ParseIP("10.128.0.0"), Last: net.ParseIP("10.255.255.255")},
+			},
+		},
+		{
+			"10.0.0.0/8", "10.255.255.255/32",
+			[]ipaddr.Range{
+				{First: net.ParseIP("10.0.0.0"), Last: net.ParseIP("10.255.255.254")},
+			},
+		},
+		{"10.0.0.0/8", "10.0.0.0/8", nil},
+		{"10.0.0.0/8", "192.0.2.0/24", nil},
+		{"10.1.0.0/16", "10.0.0.0/8", nil},
+
+		{
+			"2001:db8::/32", "2001:db8:1::/48",
+			[]ipaddr.Range{
+				{First: net.ParseIP("2001:db8::"), Last: net.ParseIP("2001:db8:0:ffff:ffff:ffff:ffff:ffff")},
+				{First: net.ParseIP("2001:db8:2::"), Last: net.ParseIP("2001:db8:ffff:ffff:ffff:ffff:ffff:ffff")},
+			},
+		},
+	} {
+		p, excl := toPrefix(tt.in), toPrefix(tt.excl)
+		rs := p.ExcludeAsRanges(excl)
+		if !reflect.DeepEqual(rs, tt.want) {
+			t.Errorf("#%d: got %v; want %v", i, rs, tt.want)
+		}
+	}
+}
+
+func TestPrefixFirstLastHost(t *testing.T) {
+	for i, tt := range []struct {
+		in          string
+		first, last net.IP
+	}{
+		{"192.168.0.0/24", net.ParseIP("192.168.0.1"), net.ParseIP("192.168.0.254")},
+		{"192.168.0.0/30", net.ParseIP("192.168.0.1"), net.ParseIP("192.168.0.2")},
+		{"192.168.0.0/31", net.ParseIP("192.168.0.0"), net.ParseIP("192.168.0.1")},
+		{"192.168.0.1/32", net.ParseIP("192.168.0.1"), net.ParseIP("192.168.0.1")},
+
+		{"2001:db8::/64", net.ParseIP("2001:db8::1"), net.ParseIP("2001:db8::ffff:ffff:ffff:ffff")},
+		{"2001:db8::/127", net.ParseIP("2001:db8::1"), net.ParseIP("2001:db8::1")},
+		{"2001:db8::1/128", net.ParseIP("2001:db8::1"), net.ParseIP("2001:db8::1")},
+	} {
+		p := toPrefix(tt.in)
+		if ip := p.FirstHost(); !ip.Equal(tt.first) {
+			t.Errorf("#%d: got %v; want %v", i, ip, tt.first)
+		}
+		if ip := p.LastHost(); !ip.Equal(tt.last) {
+			t.Errorf("#%d: got %v; want %v", i, ip, tt.last)
+		}
+	}
+}
+
+func TestPrefixFirstOverlap(t *testing.T) {
+	others := toPrefixes([]string{"198.51.100.0/24", "192.0.2.128/25", "192.0.2.0/24", "2001:db8::/32", "2001:db8:1::/48"})
+	for i, tt := range []struct {
+		in   string
+		want *ipaddr.Prefix
+		ok   bool
+	}{
+		{"192.0.2.192/26", toPrefix("192.0.2.128/25"), true},
+		{"192.0.2.0/25", toPrefix("192.0.2.0/24"), true},
+		{"192.0.0.0/16", toPrefix("192.0.2.128/25"), true},
+		{"203.0.113.0/24", nil, false},
+
+		{"2001:db8:1::/64", toPrefix("2001:db8::/32"), true},
+		{"2001:db9::/32", nil, false},
+		{"::/0", toPrefix("2001:db8::/32"), true},
+	} {
+		p := toPrefix(tt.in)
+		q, ok := p.FirstOverlap(others)
+		if ok != tt.ok || !reflect.DeepEqual(q, tt.want) {
+			t.Errorf("#%d: got %v, %v; want %v, %v", i, q, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestPrefixGroupByChild(t *testing.T) {
+	gs := toPrefix("10.0.0.0/16").GroupByChild(24)
+	if len(gs) != 256 {
+		t.Fatalf("got %v; want 256", len(gs))
+	}
+	for i, g := range gs {
+		if want := fmt.Sprintf("10.0.%d.0/24", i); g.Child.String() != want {
+			t.Errorf("#%d: got %v; want %v", i, g.Child, want)
+		}
+		n := 0
+		g.Addrs(func(ip net.IP) bool {
+			if !g.Child.IPNet.Contains(ip) {
+				t.Errorf("#%d: %v must contain %v", i, g.Child, ip)
+			}
+			n++
+			return true
+		})
+		if n != 256 {
+			t.Errorf("#%d: got %v; want 256", i, n)
+		}
+	}
+
+	gs = toPrefix("2001:db8::/32").GroupByChild(48)
+	if len(gs) != 1<<16 {
+		t.Fatalf("got %v; want %v", len(gs), 1<<16)
+	}
+	var ips []net.IP
+	gs[1].Addrs(func(ip net.IP) bool {
+		ips = append(ips, ip)
+		return len(ips) < 2
+	})
+	if want := []net.IP{net.ParseIP("2001:db8:1::"), net.ParseIP("2001:db8:1::1")}; !reflect.DeepEqual(ips, want) {
+		t.Errorf("got %v; want %v", ips, want)
+	}
+
+	if gs := toPrefix("10.0.0.0/16").GroupByChild(8); gs != nil {
+		t.Errorf("got %v; want nil", gs)
+	}
+	if gs := toPrefix("10.0.0.0/24").GroupByChild(33); gs != nil {
+		t.Errorf("got %v; want nil", len(gs))
+	}
+	if gs := toPrefix("2001:db8::/32").GroupByChild(64); gs != nil {
+		t.Errorf("got %v; want nil", len(gs))
+	}
+}
+
+func TestPrefixHostsExcluding(t *testing.T) {
+	for i, tt := range []struct {
+		in       string
+		begin    net.IP
+		reserved []net.IP
+		want     []net.IP
+	}{
+		{
+			"192.0.2.0/29", nil,
+			[]net.IP{net.ParseIP("192.0.2.1"), net.ParseIP("192.0.2.254")},
+			[]net.IP{net.ParseIP("192.0.2.2"), net.ParseIP("192.0.2.3"), net.ParseIP("192.0.2.4"), net.ParseIP("192.0.2.5"), net.ParseIP("192.0.2.6")},
+		},
+		{
+			"192.0.2.0/29", net.ParseIP("192.0.2.4"),
+			[]net.IP{net.ParseIP("192.0.2.5")},
+			[]net.IP{net.ParseIP("192.0.2.4"), net.ParseIP("192.0.2.6")},
+		},
+		{
+			"192.0.2.0/31", nil,
+			nil,
+			[]net.IP{net.ParseIP("192.0.2.0"), net.ParseIP("192.0.2.1")},
+		},
+		{
+			"192.0.2.0/29", net.ParseIP("198.51.100.1"),
+			nil,
+			nil,
+		},
+
+		{
+			"2001:db8::/126", nil,
+			[]net.IP{net.ParseIP("2001:db8::1")},
+			[]net.IP{net.ParseIP("2001:db8::2"), net.ParseIP("2001:db8::3")},
+		},
+		{
+			"2001:db8::1/128", nil,
+			nil,
+			[]net.IP{net.ParseIP("2001:db8::1")},
+		},
+		{
+			"2001:db8::/64", net.ParseIP("2001:db8::ffff:ffff:ffff:fffd"),
+			[]net.IP{net.ParseIP("2001:db8::ffff:ffff:ffff:fffe")},
+			[]net.IP{net.ParseIP("2001:db8::ffff:ffff:ffff:fffd"), net.ParseIP("2001:db8::ffff:ffff:ffff:ffff")},
+		},
+	} {
+		p := toPrefix(tt.in)
+		ips := p.HostsExcluding(tt.begin, tt.reserved)
+		if !reflect.DeepEqual(ips, tt.want) {
+			t.Errorf("#%d: got %v; want %v", i, ips, tt.want)
+		}
+	}
+	for i, tt := range []struct {
+		in    string
+		begin net.IP
+		n     int
+	}{
+		{"10.0.0.0/8", nil, 1<<17 - 1},
+		{"10.0.0.0/8", net.ParseIP("10.255.0.0"), 1<<16 - 1},
+		{"2001:db8::/64", nil, 1<<17 - 1},
+	} {
+		p := toPrefix(tt.in)
+		if ips := p.HostsExcluding(tt.begin, nil); len(ips) != tt.n {
+			t.Errorf("#%d: got %v; want %v", i, len(ips), tt.n)
+		}
+	}
+}
+
+func TestPrefixHostsFunc(t *testing.T) {
+	for i, tt := range []struct {
+		in    string
+		begin net.IP
+		max   int
+		want  []net.IP
+	}{
+		{"192.0.2.0/29", nil, 0, []net.IP{net.ParseIP("192.0.2.1"), net.ParseIP("192.0.2.2"), net.ParseIP("192.0.2.3"), net.ParseIP("192.0.2.4"), net.ParseIP("192.0.2.5"), net.ParseIP("192.0.2.6")}},
+		{"192.0.2.0/29", net.ParseIP("192.0.2.4"), 0, []net.IP{net.ParseIP("192.0.2.4"), net.ParseIP("192.0.2.5"), net.ParseIP("192.0.2.6")}},
+		{"192.0.2.0/29", nil, 2, []net.IP{net.ParseIP("192.0.2.1"), net.ParseIP("192.0.2.2")}},
+		{"192.0.2.0/31", nil, 0, []net.IP{net.ParseIP("192.0.2.0"), net.ParseIP("192.0.2.1")}},
+		{"10.0.0.0/8", nil, 2, []net.IP{net.ParseIP("10.0.0.1"), net.ParseIP("10.0.0.2")}},
+		{"192.0.2.0/29", net.ParseIP("198.51.100.1"), 0, nil},
+		{"0.0.0.0/0", nil, 2, nil},
+
+		{"2001:db8::/126", nil, 0, []net.IP{net.ParseIP("2001:db8::1"), net.ParseIP("2001:db8::2"), net.ParseIP("2001:db8::3")}},
+		{"2001:db8::1/128", nil, 0, []net.IP{net.ParseIP("2001:db8::1")}},
+		{"2001:db8::/64", nil, 1, []net.IP{net.ParseIP("2001:db8::1")}},
+		{"::/0", nil, 2, nil},
+	} {
+		p := toPrefix(tt.in)
+		var ips []net.IP
+		p.HostsFunc(tt.begin, func(ip net.IP) bool {
+			ips = append(ips, ip)
+			return len(ips) != tt.max
+		})
+		if !reflect.DeepEqual(ips, tt.want) {
+			t.Errorf("#%d: got %v; want %v", i, ips, tt.want)
+		}
+	}
+}
+
+func TestPrefixHostsMatching(t *testing.T) {
+	for i, tt := range []struct {
+		in         string
+		begin      net.IP
+		pos, nbits int
+		value      uint32
+		want       []net.IP
+	}{
+		{"192.0.2.0/28", nil, 28, 4, 5, []net.IP{net.ParseIP("192.0.2.5")}},
+		{"192.0.2.0/28", nil, 28, 4, 0, nil},
+		{"192.0.2.0/28", nil, 31, 1, 0, []net.IP{net.ParseIP("192.0.2.2"), net.ParseIP("192.0.2.4"), net.ParseIP("192.0.2.6"), net.ParseIP("192.0.2.8"), net.ParseIP("192.0.2.10"), net.ParseIP("192.0.2.12"), net.ParseIP("192.0.2.14")}},
+		{"192.0.2.0/28", net.ParseIP("192.0.2.8"), 29, 2, 3, []net.IP{net.ParseIP("192.0.2.14")}},
+		{"192.0.2.0/28", nil, 24, 8, 20, nil},
+		{"192.0.2.0/28", nil, 0, 8, 192, toPrefix("192.0.2.0/28").HostsExcluding(nil, nil)},
+		{"192.0.2.0/28", nil, 30, 4, 0, nil},
+
+		{"2001:db8::/124", nil, 124, 4, 0xa, []net.IP{net.ParseIP("2001:db8::a")}},
+		{"2001:db8::/124", nil, 16, 16, 0xdb8, toPrefix("2001:db8::/124").HostsExcluding(nil, nil)},
+	} {
+		p := toPrefix(tt.in)
+		ips := p.HostsMatching(tt.begin, tt.pos, tt.nbits, tt.value)
+		if !reflect.DeepEqual(ips, tt.want) {
+			t.Errorf("#%d: got %v; want %v", i, ips, tt.want)
+		}
+	}
+}
 
-		{toPrefixes([]string{"192.0.2.1/24", "2001:db8:1::1/64"}), false},
-		{toPrefixes([]string{"2001:db8:1::1/64", "192.0.2.1/24"}), false},
+func TestPrefixHostsStrict(t *testing.T) {
+	for i, tt := range []struct {
+		in          string
+		begin       net.IP
+		n           int
+		first, last net.IP
+	}{
+		{"2001:db8::/120", nil, 127, net.ParseIP("2001:db8::1"), net.ParseIP("2001:db8::7f")},
+		{"2001:db8::200/119", nil, 383, net.ParseIP("2001:db8::201"), net.ParseIP("2001:db8::37f")},
+		{"2001:db8::/121", nil, 127, net.ParseIP("2001:db8::1"), net.ParseIP("2001:db8::7f")},
+		{"2001:db8::/126", nil, 3, net.ParseIP("2001:db8::1"), net.ParseIP("2001:db8::3")},
+		{"192.0.2.0/24", nil, 254, net.ParseIP("192.0.2.1"), net.ParseIP("192.0.2.254")},
+		{"2001:db8::/64", net.ParseIP("2001:db8::fdff:ffff:ffff:ff7e"), 1<<17 - 128, net.ParseIP("2001:db8::fdff:ffff:ffff:ff7e"), net.ParseIP("2001:db8::fe00:0:1:ff7d")},
+		{"2001:db8::/64", net.ParseIP("2001:db8::ffff:ffff:ffff:ff00"), 256, net.ParseIP("2001:db8::ffff:ffff:ffff:ff00"), net.ParseIP("2001:db8::ffff:ffff:ffff:ffff")},
 	} {
-		if ok := tt.in[0].Contains(&tt.in[1]); ok != tt.ok {
-			t.Errorf("#%d: got %v; want %v", i, ok, tt.ok)
+		p := toPrefix(tt.in)
+		ips := p.HostsStrict(tt.begin)
+		if len(ips) != tt.n {
+			t.Errorf("#%d: got %v; want %v", i, len(ips), tt.n)
+			continue
+		}
+		if !ips[0].Equal(tt.first) || !ips[len(ips)-1].Equal(tt.last) {
+			t.Errorf("#%d: got %v, %v; want %v, %v", i, ips[0], ips[len(ips)-1], tt.first, tt.last)
 		}
 	}
 }
 
-func TestPrefixExclude(t *testing.T) {
+func TestPrefixIsAligned(t *testing.T) {
 	for i, tt := range []struct {
-		in, excl string
+		in           string
+		byte, nibble bool
 	}{
-		{"192.0.0.0/16", "192.0.2.0/24"},
-		{"192.0.2.0/24", "192.0.2.0/32"},
+		{"192.0.2.0/24", true, true},
+		{"192.0.2.0/25", false, false},
+		{"192.0.0.0/20", false, true},
+		{"0.0.0.0/0", true, true},
+		{"192.0.2.1/32", true, true},
 
-		{"2001:db8:f001::/48", "2001:db8:f001:f002::/56"},
-		{"2001:db8:f001:f002::/64", "2001:db8:f001:f002::cafe/128"},
+		{"2001:db8::/32", true, true},
+		{"2001:db8::/36", false, true},
+		{"2001:db8::/63", false, false},
+		{"2001:db8::1/128", true, true},
 	} {
-		p, excl := toPrefix(tt.in), toPrefix(tt.excl)
-		ps := p.Exclude(excl)
-		if len(ps) != excl.Len()-p.Len() {
-			for _, p := range ps {
-				t.Logf("subnet: %v", p)
-			}
-			t.Errorf("#%d: got %v; want %v", i, len(ps), excl.Len()-p.Len())
+		p := toPrefix(tt.in)
+		if ok := p.IsByteAligned(); ok != tt.byte {
+			t.Errorf("#%d: got %v; want %v", i, ok, tt.byte)
 		}
-		diff, sum := big.NewInt(0), big.NewInt(0)
-		diff.Sub(p.NumNodes(), excl.NumNodes())
-		for _, p := range ps {
-			sum.Add(sum, p.NumNodes())
+		if ok := p.IsOctetAligned(); ok != tt.byte {
+			t.Errorf("#%d: got %v; want %v", i, ok, tt.byte)
 		}
-		if diff.String() != sum.String() {
-			for _, p := range ps {
-				t.Logf("subnet: %v", p)
-			}
-			t.Errorf("#%d: got %v; want %v", i, sum.String(), diff.String())
+		if ok := p.IsNibbleAligned(); ok != tt.nibble {
+			t.Errorf("#%d: got %v; want %v", i, ok, tt.nibble)
 		}
 	}
 }
@@ -776,6 +2680,29 @@ func TestPrefixMask(t *testing.T) {
 	}
 }
 
+func TestPrefixHostmaskBytes(t *testing.T) {
+	for i, tt := range []struct {
+		in, tmp string
+	}{
+		{"192.0.2.0/24", "192.0.2.0/27"},
+		{"0.0.0.0/0", "255.255.255.255/32"},
+
+		{"2001:db8::/64", "2001:db8::/121"},
+		{"::/0", "2001:db8::1/128"},
+	} {
+		p := toPrefix(tt.in)
+		if !bytes.Equal(p.HostmaskBytes(), p.Hostmask()) {
+			t.Errorf("#%d: got %v; want %v", i, p.HostmaskBytes(), p.Hostmask())
+		}
+		if err := p.UnmarshalText([]byte(tt.tmp)); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(p.HostmaskBytes(), p.Hostmask()) {
+			t.Errorf("#%d: got %v; want %v", i, p.HostmaskBytes(), p.Hostmask())
+		}
+	}
+}
+
 func TestPrefixNumNodes(t *testing.T) {
 	for i, tt := range []struct {
 		in string
@@ -798,6 +2725,33 @@ func TestPrefixNumNodes(t *testing.T) {
 	}
 }
 
+func TestPrefixOffset(t *testing.T) {
+	for i, tt := range []struct {
+		in   string
+		ip   net.IP
+		want string
+		ok   bool
+	}{
+		{"192.168.0.0/24", net.ParseIP("192.168.0.5"), "5", true},
+		{"192.168.0.0/24", net.ParseIP("192.168.0.0"), "0", true},
+		{"192.168.0.0/24", net.ParseIP("192.168.0.255"), "255", true},
+		{"0.0.0.0/0", net.ParseIP("255.255.255.255"), "4294967295", true},
+		{"192.168.0.0/24", net.ParseIP("192.168.1.5"), "", false},
+		{"192.168.0.0/24", net.ParseIP("2001:db8::1"), "", false},
+
+		{"2001:db8::/64", net.ParseIP("2001:db8::1:0:0:5"), "281474976710661", true},
+		{"::/0", net.ParseIP("ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff"), "340282366920938463463374607431768211455", true},
+		{"::/64", net.ParseIP("::ffff:192.168.0.5"), "", false},
+		{"2001:db8::/64", net.ParseIP("2001:db8:0:1::"), "", false},
+	} {
+		p := toPrefix(tt.in)
+		off, ok := p.Offset(tt.ip)
+		if ok != tt.ok || ok && off.String() != tt.want {
+			t.Errorf("#%d: got %v, %v; want %v, %v", i, off, ok, tt.want, tt.ok)
+		}
+	}
+}
+
 func TestPrefixOverlaps(t *testing.T) {
 	for i, tt := range []struct {
 		in     string
@@ -822,6 +2776,397 @@ func TestPrefixOverlaps(t *testing.T) {
 			t.Errorf("#%d: got %v; want %v", i, out, tt.want)
 		}
 	}
+
+	for i, tt := range []struct {
+		a, b string
+		want bool
+	}{
+		{"192.168.0.0/24", "192.168.1.0/24", false},
+		{"192.168.0.255/32", "192.168.1.0/32", false},
+		{"192.168.0.0/31", "192.168.0.2/31", false},
+		{"192.168.0.0/31", "192.168.0.1/32", true},
+		{"192.168.0.2/31", "192.168.0.1/32", false},
+		{"192.168.0.1/32", "192.168.0.1/32", true},
+		{"127.255.255.255/32", "128.0.0.0/1", false},
+
+		{"2001:db8::/64", "2001:db8:0:1::/64", false},
+		{"2001:db8::ffff/128", "2001:db8::1:0/128", false},
+		{"2001:db8::/127", "2001:db8::2/127", false},
+		{"2001:db8::/127", "2001:db8::1/128", true},
+		{"2001:db8::2/127", "2001:db8::1/128", false},
+		{"0:0:0:0:ffff:ffff:ffff:ffff/128", "0:0:0:1::/64", false},
+	} {
+		a, b := toPrefix(tt.a), toPrefix(tt.b)
+		if out := a.Overlaps(b); out != tt.want {
+			t.Errorf("#%d: got %v; want %v", i, out, tt.want)
+		}
+		if out := b.Overlaps(a); out != tt.want {
+			t.Errorf("#%d: got %v; want %v", i, out, tt.want)
+		}
+	}
+}
+
+func TestPrefixReservedAnycast(t *testing.T) {
+	for i, tt := range []struct {
+		in   string
+		want *ipaddr.Range
+	}{
+		{"2001:db8::/48", &ipaddr.Range{First: net.ParseIP("2001:db8:0:ffff:fdff:ffff:ffff:ff80"), Last: net.ParseIP("2001:db8:0:ffff:fdff:ffff:ffff:ffff")}},
+		{"2001:db8::/64", &ipaddr.Range{First: net.ParseIP("2001:db8::fdff:ffff:ffff:ff80"), Last: net.ParseIP("2001:db8::fdff:ffff:ffff:ffff")}},
+		{"2001:db8::/96", &ipaddr.Range{First: net.ParseIP("2001:db8::ffff:ff80"), Last: net.ParseIP("2001:db8::ffff:ffff")}},
+		{"2001:db8::100/120", &ipaddr.Range{First: net.ParseIP("2001:db8::180"), Last: net.ParseIP("2001:db8::1ff")}},
+		{"2001:db8::/121", nil},
+		{"192.168.0.0/16", nil},
+	} {
+		r, ok := toPrefix(tt.in).ReservedAnycast()
+		if ok != (tt.want != nil) {
+			t.Errorf("#%d: got %v; want %v", i, ok, tt.want != nil)
+			continue
+		}
+		if ok && (!r.First.Equal(tt.want.First) || !r.Last.Equal(tt.want.Last)) {
+			t.Errorf("#%d: got %v; want %v", i, r, *tt.want)
+		}
+	}
+}
+
+func TestPrefixSafeSupernet(t *testing.T) {
+	for i, tt := range []struct {
+		in        string
+		forbidden []string
+		want      string
+	}{
+		{"192.168.0.0/24", []string{"192.168.2.0/24"}, "192.168.0.0/23"},
+		{"192.168.0.0/24", []string{"192.168.1.0/25"}, "192.168.0.0/24"},
+		{"192.168.0.0/24", []string{"192.168.128.0/17", "2001:db8::/32"}, "192.168.0.0/17"},
+		{"192.168.0.0/24", []string{"10.0.0.0/8"}, "128.0.0.0/1"},
+		{"192.168.0.0/24", nil, "0.0.0.0/0"},
+		{"192.168.0.0/24", []string{"192.168.0.128/25"}, ""},
+		{"192.168.0.0/24", []string{"192.168.0.0/24"}, ""},
+		{"192.168.0.0/24", []string{"192.0.0.0/8"}, ""},
+
+		{"2001:db8::/48", []string{"2001:db8:1::/48"}, "2001:db8::/48"},
+		{"2001:db8::/48", []string{"2001:db9::/32", "10.0.0.0/8"}, "2001:db8::/32"},
+	} {
+		p := toPrefix(tt.in)
+		if q := p.SafeSupernet(toPrefixes(tt.forbidden)); !reflect.DeepEqual(q, toPrefix(tt.want)) {
+			t.Errorf("#%d: got %v; want %v", i, q, tt.want)
+		}
+	}
+}
+
+func TestPrefixSiblingSpace(t *testing.T) {
+	for i, tt := range []struct {
+		in   string
+		want []string
+	}{
+		{"192.168.0.0/24", []string{"192.168.1.0/24"}},
+		{"192.168.1.0/24", []string{"192.168.0.0/24"}},
+		{"128.0.0.0/1", []string{"0.0.0.0/1"}},
+		{"192.0.2.255/32", []string{"192.0.2.254/32"}},
+		{"0.0.0.0/0", nil},
+
+		{"2001:db8::/32", []string{"2001:db9::/32"}},
+		{"2001:db8::1/128", []string{"2001:db8::/128"}},
+		{"::/0", nil},
+	} {
+		p := toPrefix(tt.in)
+		if ps := p.SiblingSpace(); !reflect.DeepEqual(ps, toPrefixes(tt.want)) {
+			t.Errorf("#%d: got %v; want %v", i, ps, tt.want)
+		}
+	}
+}
+
+func TestPrefixSnapLen(t *testing.T) {
+	for i, tt := range []struct {
+		in       string
+		boundary int
+		up       bool
+		want     string
+	}{
+		{"192.0.2.32/27", 8, false, "192.0.2.0/24"},
+		{"192.0.2.32/27", 8, true, "192.0.2.32/32"},
+		{"192.0.2.0/24", 8, false, "192.0.2.0/24"},
+		{"192.0.2.0/24", 8, true, "192.0.2.0/24"},
+		{"192.0.0.0/18", 16, true, "192.0.0.0/32"},
+		{"192.0.2.32/27", 0, true, "192.0.2.32/27"},
+
+		{"2001:db8:1230::/46", 4, false, "2001:db8:1230::/44"},
+		{"2001:db8:1230::/46", 4, true, "2001:db8:1230::/48"},
+		{"2001:db8::/127", 16, true, "2001:db8::/128"},
+	} {
+		p := toPrefix(tt.in)
+		if q := p.SnapLen(tt.boundary, tt.up); !q.Equal(toPrefix(tt.want)) {
+			t.Errorf("#%d: got %v; want %v", i, q, tt.want)
+		}
+	}
+}
+
+func TestPrefixSortKey(t *testing.T) {
+	for i, tt := range []struct {
+		in   string
+		want string
+	}{
+		{"192.168.0.0/24", "192.168.000.000/024"},
+		{"0.0.0.0/0", "000.000.000.000/000"},
+		{"10.0.0.1/32", "010.000.000.001/032"},
+
+		{"2001:db8::/32", "2001:0db8:0000:0000:0000:0000:0000:0000/032"},
+		{"::1/128", "0000:0000:0000:0000:0000:0000:0000:0001/128"},
+	} {
+		p := toPrefix(tt.in)
+		if out := p.SortKey(); out != tt.want {
+			t.Errorf("#%d: got %v; want %v", i, out, tt.want)
+		}
+		pp, err := ipaddr.ParseSortKey(tt.want)
+		if err != nil {
+			t.Errorf("#%d: %v", i, err)
+			continue
+		}
+		if !reflect.DeepEqual(pp, p) {
+			t.Errorf("#%d: got %v; want %v", i, pp, p)
+		}
+	}
+
+	for i, ss := range [][]string{
+		{"192.0.2.0/24", "10.0.0.0/8", "192.0.2.0/25", "9.255.0.0/16", "10.0.0.0/7", "192.0.2.128/25", "100.64.0.0/10", "0.0.0.0/0"},
+		{"2001:db8::/32", "2001:db8::/48", "fe80::/10", "::1/128", "2001:db8:0:1::/64", "::/0", "ff02::1/128", "2001:db7::/32"},
+	} {
+		ps := toPrefixes(ss)
+		keys := make([]string, len(ps))
+		for j := range ps {
+			keys[j] = ps[j].SortKey()
+		}
+		sort.Strings(keys)
+		sort.Sort(byAscending(ps))
+		for j := range ps {
+			if keys[j] != ps[j].SortKey() {
+				t.Errorf("#%d: got %v; want %v", i, keys, ps)
+				break
+			}
+		}
+	}
+
+	for i, s := range []string{"192.168.0.0", "192.168.000.256/024", "192.168.0.0/33", "2001:db8::/129", "::ffff:192.0.2.0/120", "192.168.0/16"} {
+		if _, err := ipaddr.ParseSortKey(s); err == nil {
+			t.Errorf("#%d: %v should fail", i, s)
+		}
+	}
+}
+
+func TestPrefixSpecialAddrs(t *testing.T) {
+	for i, tt := range []struct {
+		in   string
+		want map[string]net.IP
+	}{
+		{"192.168.0.0/24", map[string]net.IP{"network": net.ParseIP("192.168.0.0"), "broadcast": net.ParseIP("192.168.0.255")}},
+		{"192.168.0.0/30", map[string]net.IP{"network": net.ParseIP("192.168.0.0"), "broadcast": net.ParseIP("192.168.0.3")}},
+		{"192.168.0.0/31", map[string]net.IP{}},
+		{"192.168.0.1/32", map[string]net.IP{}},
+
+		{
+			"2001:db8::/64",
+			map[string]net.IP{
+				"subnet-router-anycast": net.ParseIP("2001:db8::"),
+			},
+		},
+		{
+			"2001:db8::100/120",
+			map[string]net.IP{
+				"subnet-router-anycast": net.ParseIP("2001:db8::100"),
+			},
+		},
+		{"2001:db8::/121", map[string]net.IP{"subnet-router-anycast": net.ParseIP("2001:db8::")}},
+		{"2001:db8::1/128", map[string]net.IP{}},
+	} {
+		p := toPrefix(tt.in)
+		if m := p.SpecialAddrs(); !reflect.DeepEqual(m, tt.want) {
+			t.Errorf("#%d: got %v; want %v", i, m, tt.want)
+		}
+	}
+}
+
+func TestPrefixSplitAt(t *testing.T) {
+	for i, tt := range []struct {
+		in           string
+		lower, upper *ipaddr.Prefix
+		pivot        net.IP
+		ok           bool
+	}{
+		{"192.0.2.0/24", toPrefix("192.0.2.0/25"), toPrefix("192.0.2.128/25"), net.ParseIP("192.0.2.128"), true},
+		{"0.0.0.0/0", toPrefix("0.0.0.0/1"), toPrefix("128.0.0.0/1"), net.ParseIP("128.0.0.0"), true},
+		{"192.0.2.1/32", nil, nil, nil, false},
+
+		{"2001:db8::/32", toPrefix("2001:db8::/33"), toPrefix("2001:db8:8000::/33"), net.ParseIP("2001:db8:8000::"), true},
+		{"2001:db8::/127", toPrefix("2001:db8::/128"), toPrefix("2001:db8::1/128"), net.ParseIP("2001:db8::1"), true},
+		{"2001:db8::1/128", nil, nil, nil, false},
+	} {
+		p := toPrefix(tt.in)
+		lower, upper, pivot, ok := p.SplitAt()
+		if ok != tt.ok {
+			t.Errorf("#%d: got %v; want %v", i, ok, tt.ok)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if !lower.Equal(tt.lower) || !upper.Equal(tt.upper) {
+			t.Errorf("#%d: got %v, %v; want %v, %v", i, lower, upper, tt.lower, tt.upper)
+		}
+		if !pivot.Equal(tt.pivot) || !pivot.Equal(upper.IP) {
+			t.Errorf("#%d: got %v; want %v", i, pivot, tt.pivot)
+		}
+	}
+}
+
+func TestPrefixSplitByRatio(t *testing.T) {
+	for i, tt := range []struct {
+		in     string
+		ratios []float64
+		want   []string
+	}{
+		{"192.168.0.0/24", []float64{0.5, 0.25, 0.25}, []string{"192.168.0.0/25", "192.168.0.128/26", "192.168.0.192/26"}},
+		{"192.168.0.0/24", []float64{0.25, 0.5, 0.25}, []string{"192.168.0.128/26", "192.168.0.0/25", "192.168.0.192/26"}},
+		{"192.168.0.0/24", []float64{0.4, 0.3, 0.3}, []string{"192.168.0.0/25", "192.168.0.128/26", "192.168.0.192/26"}},
+		{"192.168.0.0/24", []float64{0.75, 0.25}, []string{"192.168.0.0/25", "192.168.0.128/26"}},
+		{"192.168.0.0/24", []float64{1}, []string{"192.168.0.0/24"}},
+		{"192.168.0.0/30", []float64{0.25, 0.25, 0.25, 0.25}, []string{"192.168.0.0/32", "192.168.0.1/32", "192.168.0.2/32", "192.168.0.3/32"}},
+		{"192.168.0.0/24", []float64{0.5, 0.25}, nil},
+		{"192.168.0.0/24", []float64{0.5, 0.5, 0}, nil},
+		{"192.168.0.0/24", nil, nil},
+		{"192.168.0.1/32", []float64{0.5, 0.5}, nil},
+
+		{"2001:db8::/32", []float64{0.5, 0.125, 0.375}, []string{"2001:db8::/33", "2001:db8:c000::/35", "2001:db8:8000::/34"}},
+	} {
+		p := toPrefix(tt.in)
+		ps, err := p.SplitByRatio(tt.ratios)
+		if (err != nil) != (tt.want == nil) {
+			t.Errorf("#%d: got %v, %v; want %v", i, ps, err, tt.want)
+			continue
+		}
+		if !reflect.DeepEqual(ps, toPrefixes(tt.want)) {
+			t.Errorf("#%d: got %v; want %v", i, ps, tt.want)
+		}
+	}
+}
+
+func TestPrefixSubnetAt(t *testing.T) {
+	for i, tt := range []struct {
+		in   string
+		n    int
+		i    *big.Int
+		want string
+	}{
+		{"192.168.0.0/24", 2, big.NewInt(1), "192.168.0.64/26"},
+		{"192.168.0.0/24", 2, big.NewInt(3), "192.168.0.192/26"},
+		{"192.168.0.0/24", 8, big.NewInt(255), "192.168.0.255/32"},
+		{"0.0.0.0/0", 0, big.NewInt(0), "0.0.0.0/0"},
+		{"192.168.0.0/24", 2, big.NewInt(4), ""},
+		{"192.168.0.0/24", 9, big.NewInt(0), ""},
+		{"192.168.0.0/24", -1, big.NewInt(0), ""},
+		{"192.168.0.0/24", 2, big.NewInt(-1), ""},
+
+		{"2001:db8::/32", 16, big.NewInt(0xcafe), "2001:db8:cafe::/48"},
+		{"2001:db8::/64", 64, big.NewInt(1), "2001:db8::1/128"},
+		{"::/0", 128, new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 128), big.NewInt(1)), "ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff/128"},
+		{"2001:db8::/64", 65, big.NewInt(0), ""},
+		{"2001:db8::/64", 4, big.NewInt(16), ""},
+	} {
+		p := toPrefix(tt.in)
+		out, err := p.SubnetAt(tt.n, tt.i)
+		if tt.want == "" {
+			if err == nil {
+				t.Errorf("#%d: got %v; want an error", i, out)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("#%d: %v", i, err)
+			continue
+		}
+		if !out.Equal(toPrefix(tt.want)) {
+			t.Errorf("#%d: got %v; want %v", i, out, tt.want)
+		}
+	}
+	p := toPrefix("2001:db8::80/121")
+	for i, s := range p.Subnets(3) {
+		out, err := p.SubnetAt(3, big.NewInt(int64(i)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !out.Equal(&s) {
+			t.Errorf("#%d: got %v; want %v", i, out, s)
+		}
+	}
+}
+
+func TestPrefixSubnetHistogram(t *testing.T) {
+	for i, tt := range []struct {
+		in   string
+		ips  []net.IP
+		n    int
+		want []int
+	}{
+		{
+			"192.0.2.0/24",
+			[]net.IP{net.ParseIP("192.0.2.1"), net.ParseIP("192.0.2.63"), net.ParseIP("192.0.2.64"), net.ParseIP("192.0.2.200"), net.ParseIP("192.0.2.255"), net.ParseIP("192.0.3.1"), net.ParseIP("2001:db8::1")},
+			2,
+			[]int{2, 1, 0, 2},
+		},
+		{"192.0.2.0/24", []net.IP{net.ParseIP("192.0.2.1")}, 0, []int{1}},
+		{"192.0.2.0/24", nil, 9, nil},
+
+		{
+			"2001:db8::/32",
+			[]net.IP{net.ParseIP("2001:db8::1"), net.ParseIP("2001:db8:8000::1"), net.ParseIP("2001:db8:ffff::1"), net.ParseIP("192.0.2.1")},
+			1,
+			[]int{1, 2},
+		},
+	} {
+		p := toPrefix(tt.in)
+		if hist := p.SubnetHistogram(tt.ips, tt.n); !reflect.DeepEqual(hist, tt.want) {
+			t.Errorf("#%d: got %v; want %v", i, hist, tt.want)
+		}
+	}
+}
+
+func TestPrefixSubnetLevels(t *testing.T) {
+	for i, tt := range []struct {
+		in        string
+		targetLen int
+		want      [][]string
+	}{
+		{
+			"192.0.2.0/24", 26,
+			[][]string{
+				{"192.0.2.0/24"},
+				{"192.0.2.0/25", "192.0.2.128/25"},
+				{"192.0.2.0/26", "192.0.2.64/26", "192.0.2.128/26", "192.0.2.192/26"},
+			},
+		},
+		{"192.0.2.0/24", 24, [][]string{{"192.0.2.0/24"}}},
+		{"192.0.2.0/24", 23, nil},
+		{"192.0.2.0/24", 33, nil},
+		{"0.0.0.0/0", 32, nil},
+
+		{
+			"2001:db8::/32", 33,
+			[][]string{
+				{"2001:db8::/32"},
+				{"2001:db8::/33", "2001:db8:8000::/33"},
+			},
+		},
+		{"2001:db8::/120", 129, nil},
+	} {
+		p := toPrefix(tt.in)
+		levels := p.SubnetLevels(tt.targetLen)
+		var want [][]ipaddr.Prefix
+		for _, ss := range tt.want {
+			want = append(want, toPrefixes(ss))
+		}
+		if !reflect.DeepEqual(levels, want) {
+			t.Errorf("#%d: got %v; want %v", i, levels, want)
+		}
+	}
 }
 
 func TestPrefixSubnets(t *testing.T) {
@@ -860,6 +3205,82 @@ func TestPrefixSubnets(t *testing.T) {
 	}
 }
 
+func TestPrefixSubnetsIndexed(t *testing.T) {
+	for i, tt := range []struct {
+		in   string
+		n    int
+		want []string
+	}{
+		{"192.0.2.0/24", 2, []string{"192.0.2.0/26", "192.0.2.64/26", "192.0.2.128/26", "192.0.2.192/26"}},
+		{"192.0.2.0/24", 0, []string{"192.0.2.0/24"}},
+		{"192.0.2.0/24", -1, nil},
+
+		{"2001:db8::/32", 1, []string{"2001:db8::/33", "2001:db8:8000::/33"}},
+	} {
+		p := toPrefix(tt.in)
+		var want []ipaddr.IndexedPrefix
+		for j, p := range toPrefixes(tt.want) {
+			want = append(want, ipaddr.IndexedPrefix{Index: j, Prefix: p})
+		}
+		if ips := p.SubnetsIndexed(tt.n); !reflect.DeepEqual(ips, want) {
+			t.Errorf("#%d: got %v; want %v", i, ips, want)
+		}
+		var ips []ipaddr.IndexedPrefix
+		p.SubnetsIndexedFunc(tt.n, func(j int, sub *ipaddr.Prefix) bool {
+			ips = append(ips, ipaddr.IndexedPrefix{Index: j, Prefix: *sub})
+			return true
+		})
+		if !reflect.DeepEqual(ips, want) {
+			t.Errorf("#%d: got %v; want %v", i, ips, want)
+		}
+	}
+
+	var last int
+	toPrefix("2001:db8::/32").SubnetsIndexedFunc(32, func(i int, sub *ipaddr.Prefix) bool {
+		last = i
+		return !sub.Equal(toPrefix("2001:db8:0:3::/64"))
+	})
+	if last != 3 {
+		t.Errorf("got %v; want 3", last)
+	}
+
+	for _, n := range []int{strconv.IntSize - 1, strconv.IntSize} {
+		called := false
+		toPrefix("::/0").SubnetsIndexedFunc(n, func(i int, sub *ipaddr.Prefix) bool {
+			called = true
+			return false
+		})
+		if want := n < strconv.IntSize; called != want {
+			t.Errorf("%d bits: got %v; want %v", n, called, want)
+		}
+	}
+}
+
+func TestPrefixSummary(t *testing.T) {
+	for i, tt := range []struct {
+		in                              string
+		network, first, last, broadcast net.IP
+	}{
+		{"192.0.2.0/24", net.ParseIP("192.0.2.0"), net.ParseIP("192.0.2.1"), net.ParseIP("192.0.2.254"), net.ParseIP("192.0.2.255")},
+		{"192.0.2.0/30", net.ParseIP("192.0.2.0"), net.ParseIP("192.0.2.1"), net.ParseIP("192.0.2.2"), net.ParseIP("192.0.2.3")},
+		{"192.0.2.0/31", net.ParseIP("192.0.2.0"), net.ParseIP("192.0.2.0"), net.ParseIP("192.0.2.1"), nil},
+		{"192.0.2.1/32", net.ParseIP("192.0.2.1"), net.ParseIP("192.0.2.1"), net.ParseIP("192.0.2.1"), nil},
+
+		{"2001:db8::/64", net.ParseIP("2001:db8::"), net.ParseIP("2001:db8::1"), net.ParseIP("2001:db8::ffff:ffff:ffff:ffff"), nil},
+		{"2001:db8::/127", net.ParseIP("2001:db8::"), net.ParseIP("2001:db8::1"), net.ParseIP("2001:db8::1"), nil},
+		{"2001:db8::1/128", net.ParseIP("2001:db8::1"), net.ParseIP("2001:db8::1"), net.ParseIP("2001:db8::1"), nil},
+	} {
+		p := toPrefix(tt.in)
+		network, first, last, broadcast := p.Summary()
+		if !network.Equal(tt.network) || !first.Equal(tt.first) || !last.Equal(tt.last) || !broadcast.Equal(tt.broadcast) {
+			t.Errorf("#%d: got %v, %v, %v, %v; want %v, %v, %v, %v", i, network, first, last, broadcast, tt.network, tt.first, tt.last, tt.broadcast)
+		}
+		if !first.Equal(p.FirstHost()) || !last.Equal(p.LastHost()) {
+			t.Errorf("#%d: got %v, %v; want %v, %v", i, first, last, p.FirstHost(), p.LastHost())
+		}
+	}
+}
+
 func TestPrefixTextMarshalerUnmarshaler(t *testing.T) {
 	for i, tt := range []struct {
 		in, tmp string
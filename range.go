@@ -0,0 +1,232 @@
+// Copyright 2013 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.
+
+package ipaddr
+
+import (
+	"math/big"
+	"math/bits"
+	"net"
+	"sort"
+)
+
+// A Range represents an inclusive range of IP addresses.
+type Range struct {
+	First net.IP // first IP address
+	Last  net.IP // last IP address
+}
+
+// AlignTo returns the range that is widened from r so that the first
+// address is the network address of the prefix of length prefixLen
+// containing the first address of r, and the last address is the
+// last address of the prefix of length prefixLen containing the last
+// address of r.
+// It returns r when r is not a valid range or prefixLen is out of
+// range.
+func (r Range) AlignTo(prefixLen int) Range {
+	afi, _, _ := r.family()
+	z := IPv6PrefixLen
+	if afi == 1 {
+		z = IPv4PrefixLen
+	}
+	if afi == 0 || prefixLen < 0 || prefixLen > z {
+		return r
+	}
+	return Range{First: ipToPrefix(r.First, prefixLen, z).IP, Last: ipToPrefix(r.Last, prefixLen, z).Last()}
+}
+
+// Blocks returns a list of all the prefixes of length blockLen that
+// overlap with r.
+// It returns nil when r is not a valid range, blockLen is out of
+// range, or r overlaps with more than 2^17 such prefixes; use
+// BlocksFunc for the latter.
+func (r Range) Blocks(blockLen int) []Prefix {
+	var ps []Prefix
+	r.BlocksFunc(blockLen, func(p *Prefix) bool {
+		if len(ps) == 1<<17 { // don't bother runtime.growslice by big numbers
+			ps = nil
+			return false
+		}
+		ps = append(ps, *p)
+		return true
+	})
+	return ps
+}
+
+// BlocksFunc calls fn for each prefix of length blockLen that
+// overlaps with r, in ascending order.
+// It stops when fn returns false.
+func (r Range) BlocksFunc(blockLen int, fn func(*Prefix) bool) {
+	afi, _, end := r.family()
+	z := IPv6PrefixLen
+	if afi == 1 {
+		z = IPv4PrefixLen
+	}
+	if afi == 0 || blockLen < 0 || blockLen > z {
+		return
+	}
+	lastFn := (*Prefix).lastIPv6Int
+	if afi == 1 {
+		lastFn = (*Prefix).lastIPv4MappedIPv6Int
+	}
+	ip := r.First
+	for {
+		b := ipToPrefix(ip, blockLen, z)
+		if !fn(b) {
+			return
+		}
+		last := lastFn(b)
+		if last.cmp(&end) >= 0 {
+			return
+		}
+		last.incr()
+		ip = last.ip()
+	}
+}
+
+// EnclosingPrefix returns the shortest prefix that contains all the
+// addresses in r, which is determined by the leading bits that the
+// first and last addresses of r have in common.
+// It returns false when r is not a valid range.
+func (r Range) EnclosingPrefix() (*Prefix, bool) {
+	afi, first, last := r.family()
+	if afi == 0 {
+		return nil, false
+	}
+	z := IPv6PrefixLen
+	if afi == 1 {
+		z = IPv4PrefixLen
+	}
+	n := bits.LeadingZeros64(first[0] ^ last[0])
+	if n == 64 {
+		n += bits.LeadingZeros64(first[1] ^ last[1])
+	}
+	return ipToPrefix(r.First, n-(IPv6PrefixLen-z), z), true
+}
+
+// family returns the address family of r and the first and last
+// addresses of r as integers.
+// It returns 0 when r is not a valid range.
+func (r Range) family() (afi int, first, last ipv6Int) {
+	if r.First.To16() == nil || r.Last.To16() == nil || (r.First.To4() != nil) != (r.Last.To4() != nil) {
+		return 0, first, last
+	}
+	first, last = ipToIPv6Int(r.First.To16()), ipToIPv6Int(r.Last.To16())
+	if first.cmp(&last) > 0 {
+		return 0, first, last
+	}
+	if r.First.To4() != nil {
+		return 1, first, last
+	}
+	return 2, first, last
+}
+
+// Intersect returns the range of addresses in both r and other.
+// It returns false when r and other are disjoint or belong to
+// different address families.
+func (r Range) Intersect(other Range) (Range, bool) {
+	afi1, first1, last1 := r.family()
+	afi2, first2, last2 := other.family()
+	if afi1 == 0 || afi1 != afi2 {
+		return Range{}, false
+	}
+	if first1.cmp(&first2) < 0 {
+		first1 = first2
+	}
+	if last1.cmp(&last2) > 0 {
+		last1 = last2
+	}
+	if first1.cmp(&last1) > 0 {
+		return Range{}, false
+	}
+	return Range{First: first1.ip(), Last: last1.ip()}, true
+}
+
+// Partition splits r into n contiguous sub-ranges of as equal size
+// as possible, in ascending order.
+// It returns fewer sub-ranges, each of which contains a single
+// address, when r contains fewer than n addresses.
+// It returns nil when r is not a valid range or n is not positive.
+func (r Range) Partition(n int) []Range {
+	afi, _, _ := r.family()
+	if afi == 0 || n <= 0 {
+		return nil
+	}
+	z := IPv6PrefixLen
+	if afi == 1 {
+		z = IPv4PrefixLen
+	}
+	first, last := ipToInt(r.First), ipToInt(r.Last)
+	size := new(big.Int).Sub(last, first)
+	size.Add(size, big.NewInt(1))
+	k := big.NewInt(int64(n))
+	if size.Cmp(k) < 0 {
+		k.Set(size)
+	}
+	q, rem := new(big.Int).QuoRem(size, k, new(big.Int))
+	one := big.NewInt(1)
+	var ranges []Range
+	for i := int64(0); i < k.Int64(); i++ {
+		next := new(big.Int).Add(first, q)
+		if i < rem.Int64() {
+			next.Add(next, one)
+		}
+		ranges = append(ranges, Range{First: intToIP(first, z), Last: intToIP(new(big.Int).Sub(next, one), z)})
+		first = next
+	}
+	return ranges
+}
+
+func (r Range) String() string {
+	return r.First.String() + "-" + r.Last.String()
+}
+
+// SummarizeAll summarizes ranges and returns a list of prefixes that
+// cover exactly the union of ranges, and the number of distinct
+// addresses in the union.
+// It ignores ranges whose first and last addresses belong to different
+// address families or are in the wrong order.
+func SummarizeAll(ranges []Range) (prefixes []Prefix, totalAddrs *big.Int) {
+	type span struct {
+		ipv4        bool
+		first, last ipv6Int
+	}
+	var spans []span
+	for _, r := range ranges {
+		afi, first, last := r.family()
+		if afi == 0 {
+			continue
+		}
+		spans = append(spans, span{ipv4: afi == 1, first: first, last: last})
+	}
+	sort.Slice(spans, func(i, j int) bool {
+		if spans[i].ipv4 != spans[j].ipv4 {
+			return spans[i].ipv4
+		}
+		return spans[i].first.cmp(&spans[j].first) < 0
+	})
+	merged := spans[:0]
+	for _, s := range spans {
+		if n := len(merged); n > 0 && merged[n-1].ipv4 == s.ipv4 {
+			last := &merged[n-1].last
+			next := *last
+			next.incr()
+			if s.first.cmp(last) <= 0 || s.first.cmp(&next) == 0 {
+				if s.last.cmp(last) > 0 {
+					*last = s.last
+				}
+				continue
+			}
+		}
+		merged = append(merged, s)
+	}
+	totalAddrs = new(big.Int)
+	for _, s := range merged {
+		first, last := s.first.ip(), s.last.ip()
+		prefixes = append(prefixes, Summarize(first, last)...)
+		n := new(big.Int).Sub(ipToInt(last), ipToInt(first))
+		totalAddrs.Add(totalAddrs, n.Add(n, big.NewInt(1)))
+	}
+	return prefixes, totalAddrs
+}
@@ -0,0 +1,247 @@
+// Copyright 2013 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.
+
+package ipaddr_test
+
+import (
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/mikioh/ipaddr"
+)
+
+func TestRangeAlignTo(t *testing.T) {
+	for i, tt := range []struct {
+		in        ipaddr.Range
+		prefixLen int
+		want      string
+	}{
+		{ipaddr.Range{First: net.ParseIP("10.0.0.5"), Last: net.ParseIP("10.0.2.3")}, 24, "10.0.0.0-10.0.2.255"},
+		{ipaddr.Range{First: net.ParseIP("10.0.0.0"), Last: net.ParseIP("10.0.0.255")}, 24, "10.0.0.0-10.0.0.255"},
+		{ipaddr.Range{First: net.ParseIP("10.0.0.5"), Last: net.ParseIP("10.0.0.5")}, 32, "10.0.0.5-10.0.0.5"},
+		{ipaddr.Range{First: net.ParseIP("10.0.0.5"), Last: net.ParseIP("192.0.2.1")}, 0, "0.0.0.0-255.255.255.255"},
+		{ipaddr.Range{First: net.ParseIP("10.0.0.5"), Last: net.ParseIP("10.0.2.3")}, 33, "10.0.0.5-10.0.2.3"},
+		{ipaddr.Range{First: net.ParseIP("10.0.2.3"), Last: net.ParseIP("10.0.0.5")}, 24, "10.0.2.3-10.0.0.5"},
+
+		{ipaddr.Range{First: net.ParseIP("2001:db8::1"), Last: net.ParseIP("2001:db8:0:1::1")}, 64, "2001:db8::-2001:db8:0:1:ffff:ffff:ffff:ffff"},
+	} {
+		if r := tt.in.AlignTo(tt.prefixLen); r.String() != tt.want {
+			t.Errorf("#%d: got %v; want %v", i, r, tt.want)
+		}
+	}
+}
+
+func TestRangeBlocks(t *testing.T) {
+	for i, tt := range []struct {
+		in       ipaddr.Range
+		blockLen int
+		want     []string
+	}{
+		{ipaddr.Range{First: net.ParseIP("10.0.0.128"), Last: net.ParseIP("10.0.2.5")}, 24, []string{"10.0.0.0/24", "10.0.1.0/24", "10.0.2.0/24"}},
+		{ipaddr.Range{First: net.ParseIP("10.0.0.0"), Last: net.ParseIP("10.0.0.255")}, 24, []string{"10.0.0.0/24"}},
+		{ipaddr.Range{First: net.ParseIP("10.0.0.1"), Last: net.ParseIP("10.0.0.2")}, 16, []string{"10.0.0.0/16"}},
+		{ipaddr.Range{First: net.ParseIP("255.255.255.0"), Last: net.ParseIP("255.255.255.255")}, 25, []string{"255.255.255.0/25", "255.255.255.128/25"}},
+		{ipaddr.Range{First: net.ParseIP("0.0.0.0"), Last: net.ParseIP("255.255.255.255")}, 0, []string{"0.0.0.0/0"}},
+		{ipaddr.Range{First: net.ParseIP("10.0.0.0"), Last: net.ParseIP("10.0.0.255")}, 33, nil},
+		{ipaddr.Range{First: net.ParseIP("10.0.0.255"), Last: net.ParseIP("10.0.0.0")}, 24, nil},
+		{ipaddr.Range{First: net.ParseIP("0.0.0.0"), Last: net.ParseIP("255.255.255.255")}, 32, nil},
+
+		{ipaddr.Range{First: net.ParseIP("2001:db8::ffff"), Last: net.ParseIP("2001:db8:0:1::")}, 64, []string{"2001:db8::/64", "2001:db8:0:1::/64"}},
+	} {
+		if ps := tt.in.Blocks(tt.blockLen); !reflect.DeepEqual(ps, toPrefixes(tt.want)) {
+			t.Errorf("#%d: got %v; want %v", i, ps, tt.want)
+		}
+	}
+
+	n := 0
+	r := ipaddr.Range{First: net.ParseIP("::"), Last: net.ParseIP("ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff")}
+	r.BlocksFunc(64, func(p *ipaddr.Prefix) bool {
+		n++
+		return n < 1<<18
+	})
+	if n != 1<<18 {
+		t.Errorf("got %v; want %v", n, 1<<18)
+	}
+}
+
+func TestRangeEnclosingPrefix(t *testing.T) {
+	for i, tt := range []struct {
+		in   ipaddr.Range
+		want string
+	}{
+		{ipaddr.Range{First: net.ParseIP("192.168.0.5"), Last: net.ParseIP("192.168.1.200")}, "192.168.0.0/23"},
+		{ipaddr.Range{First: net.ParseIP("192.168.0.0"), Last: net.ParseIP("192.168.0.255")}, "192.168.0.0/24"},
+		{ipaddr.Range{First: net.ParseIP("192.168.0.1"), Last: net.ParseIP("192.168.0.1")}, "192.168.0.1/32"},
+		{ipaddr.Range{First: net.ParseIP("127.255.255.255"), Last: net.ParseIP("128.0.0.0")}, "0.0.0.0/0"},
+		{ipaddr.Range{First: net.ParseIP("192.168.1.0"), Last: net.ParseIP("192.168.0.0")}, ""},
+		{ipaddr.Range{First: net.ParseIP("192.168.0.0"), Last: net.ParseIP("2001:db8::")}, ""},
+
+		{ipaddr.Range{First: net.ParseIP("2001:db8::1"), Last: net.ParseIP("2001:db8:0:1::")}, "2001:db8::/63"},
+		{ipaddr.Range{First: net.ParseIP("::"), Last: net.ParseIP("::1")}, "::/127"},
+	} {
+		p, ok := tt.in.EnclosingPrefix()
+		if ok != (tt.want != "") || !reflect.DeepEqual(p, toPrefix(tt.want)) {
+			t.Errorf("#%d: got %v, %v; want %v", i, p, ok, tt.want)
+		}
+	}
+}
+
+func TestRangeIntersect(t *testing.T) {
+	for i, tt := range []struct {
+		a, b ipaddr.Range
+		want ipaddr.Range
+		ok   bool
+	}{
+		{
+			ipaddr.Range{First: net.ParseIP("192.0.2.10"), Last: net.ParseIP("192.0.2.100")},
+			ipaddr.Range{First: net.ParseIP("192.0.2.50"), Last: net.ParseIP("192.0.2.200")},
+			ipaddr.Range{First: net.ParseIP("192.0.2.50"), Last: net.ParseIP("192.0.2.100")},
+			true,
+		},
+		{
+			ipaddr.Range{First: net.ParseIP("192.0.2.10"), Last: net.ParseIP("192.0.2.100")},
+			ipaddr.Range{First: net.ParseIP("192.0.2.20"), Last: net.ParseIP("192.0.2.30")},
+			ipaddr.Range{First: net.ParseIP("192.0.2.20"), Last: net.ParseIP("192.0.2.30")},
+			true,
+		},
+		{
+			ipaddr.Range{First: net.ParseIP("192.0.2.10"), Last: net.ParseIP("192.0.2.100")},
+			ipaddr.Range{First: net.ParseIP("192.0.2.100"), Last: net.ParseIP("192.0.2.200")},
+			ipaddr.Range{First: net.ParseIP("192.0.2.100"), Last: net.ParseIP("192.0.2.100")},
+			true,
+		},
+		{
+			ipaddr.Range{First: net.ParseIP("192.0.2.10"), Last: net.ParseIP("192.0.2.100")},
+			ipaddr.Range{First: net.ParseIP("192.0.2.101"), Last: net.ParseIP("192.0.2.200")},
+			ipaddr.Range{},
+			false,
+		},
+		{
+			ipaddr.Range{First: net.ParseIP("0.0.0.0"), Last: net.ParseIP("255.255.255.255")},
+			ipaddr.Range{First: net.ParseIP("::"), Last: net.ParseIP("ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff")},
+			ipaddr.Range{},
+			false,
+		},
+
+		{
+			ipaddr.Range{First: net.ParseIP("2001:db8::"), Last: net.ParseIP("2001:db8::ffff")},
+			ipaddr.Range{First: net.ParseIP("2001:db8::8000"), Last: net.ParseIP("2001:db8::1:0")},
+			ipaddr.Range{First: net.ParseIP("2001:db8::8000"), Last: net.ParseIP("2001:db8::ffff")},
+			true,
+		},
+	} {
+		r, ok := tt.a.Intersect(tt.b)
+		if ok != tt.ok || !reflect.DeepEqual(r, tt.want) {
+			t.Errorf("#%d: got %v, %v; want %v, %v", i, r, ok, tt.want, tt.ok)
+		}
+		r, ok = tt.b.Intersect(tt.a)
+		if ok != tt.ok || !reflect.DeepEqual(r, tt.want) {
+			t.Errorf("#%d: got %v, %v; want %v, %v", i, r, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestRangePartition(t *testing.T) {
+	for i, tt := range []struct {
+		in   ipaddr.Range
+		n    int
+		want []string
+	}{
+		{ipaddr.Range{First: net.ParseIP("10.0.0.0"), Last: net.ParseIP("10.0.3.231")}, 3, []string{"10.0.0.0-10.0.1.77", "10.0.1.78-10.0.2.154", "10.0.2.155-10.0.3.231"}},
+		{ipaddr.Range{First: net.ParseIP("10.0.0.0"), Last: net.ParseIP("10.0.0.255")}, 1, []string{"10.0.0.0-10.0.0.255"}},
+		{ipaddr.Range{First: net.ParseIP("10.0.0.0"), Last: net.ParseIP("10.0.0.2")}, 5, []string{"10.0.0.0-10.0.0.0", "10.0.0.1-10.0.0.1", "10.0.0.2-10.0.0.2"}},
+		{ipaddr.Range{First: net.ParseIP("0.0.0.0"), Last: net.ParseIP("255.255.255.255")}, 2, []string{"0.0.0.0-127.255.255.255", "128.0.0.0-255.255.255.255"}},
+		{ipaddr.Range{First: net.ParseIP("10.0.0.0"), Last: net.ParseIP("10.0.0.255")}, 0, nil},
+		{ipaddr.Range{First: net.ParseIP("10.0.0.255"), Last: net.ParseIP("10.0.0.0")}, 2, nil},
+
+		{ipaddr.Range{First: net.ParseIP("::"), Last: net.ParseIP("ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff")}, 4, []string{"::-3fff:ffff:ffff:ffff:ffff:ffff:ffff:ffff", "4000::-7fff:ffff:ffff:ffff:ffff:ffff:ffff:ffff", "8000::-bfff:ffff:ffff:ffff:ffff:ffff:ffff:ffff", "c000::-ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff"}},
+	} {
+		rs := tt.in.Partition(tt.n)
+		var out []string
+		for _, r := range rs {
+			out = append(out, r.String())
+		}
+		if !reflect.DeepEqual(out, tt.want) {
+			t.Errorf("#%d: got %v; want %v", i, out, tt.want)
+		}
+	}
+}
+
+func TestRangeString(t *testing.T) {
+	for i, tt := range []struct {
+		in   ipaddr.Range
+		want string
+	}{
+		{ipaddr.Range{First: net.ParseIP("192.0.2.1"), Last: net.ParseIP("192.0.2.254")}, "192.0.2.1-192.0.2.254"},
+		{ipaddr.Range{First: net.ParseIP("2001:db8::1"), Last: net.ParseIP("2001:db8::ff")}, "2001:db8::1-2001:db8::ff"},
+	} {
+		if s := tt.in.String(); s != tt.want {
+			t.Errorf("#%d: got %v; want %v", i, s, tt.want)
+		}
+	}
+}
+
+func TestSummarizeAll(t *testing.T) {
+	for i, tt := range []struct {
+		in    []ipaddr.Range
+		want  []string
+		total string
+	}{
+		{
+			[]ipaddr.Range{
+				{First: net.ParseIP("192.0.2.0"), Last: net.ParseIP("192.0.2.127")},
+				{First: net.ParseIP("192.0.2.64"), Last: net.ParseIP("192.0.2.255")},
+			},
+			[]string{"192.0.2.0/24"},
+			"256",
+		},
+		{
+			[]ipaddr.Range{
+				{First: net.ParseIP("192.0.2.128"), Last: net.ParseIP("192.0.2.255")},
+				{First: net.ParseIP("192.0.2.0"), Last: net.ParseIP("192.0.2.127")},
+				{First: net.ParseIP("192.0.2.10"), Last: net.ParseIP("192.0.2.20")},
+			},
+			[]string{"192.0.2.0/24"},
+			"256",
+		},
+		{
+			[]ipaddr.Range{
+				{First: net.ParseIP("192.0.2.1"), Last: net.ParseIP("192.0.2.3")},
+				{First: net.ParseIP("192.0.2.8"), Last: net.ParseIP("192.0.2.9")},
+				{First: net.ParseIP("192.0.2.2"), Last: net.ParseIP("192.0.2.1")},
+				{First: net.ParseIP("192.0.2.2"), Last: net.ParseIP("2001:db8::")},
+			},
+			[]string{"192.0.2.1/32", "192.0.2.2/31", "192.0.2.8/31"},
+			"5",
+		},
+		{
+			[]ipaddr.Range{
+				{First: net.ParseIP("0.0.0.0"), Last: net.ParseIP("255.255.255.255")},
+				{First: net.ParseIP("128.0.0.0"), Last: net.ParseIP("255.255.255.255")},
+				{First: net.ParseIP("2001:db8::"), Last: net.ParseIP("2001:db8::ffff")},
+				{First: net.ParseIP("2001:db8::8000"), Last: net.ParseIP("2001:db8::1:ffff")},
+			},
+			[]string{"0.0.0.0/0", "2001:db8::/111"},
+			"4295098368",
+		},
+		{
+			[]ipaddr.Range{
+				{First: net.ParseIP("::"), Last: net.ParseIP("ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff")},
+				{First: net.ParseIP("ffff::"), Last: net.ParseIP("ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff")},
+			},
+			[]string{"::/0"},
+			"340282366920938463463374607431768211456",
+		},
+		{nil, nil, "0"},
+	} {
+		ps, total := ipaddr.SummarizeAll(tt.in)
+		if !reflect.DeepEqual(ps, toPrefixes(tt.want)) {
+			t.Errorf("#%d: got %v; want %v", i, ps, tt.want)
+		}
+		if total.String() != tt.total {
+			t.Errorf("#%d: got %v; want %v", i, total, tt.total)
+		}
+	}
+}
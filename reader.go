@@ -0,0 +1,67 @@
+// Copyright 2013 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.
+
+package ipaddr
+
+import (
+	"io"
+	"net"
+)
+
+// An addrReader reads a text form of IP addresses in an address
+// range, one address per line.
+type addrReader struct {
+	curr, end ipv6Int
+	ipv4      bool
+	eof       bool
+	buf       []byte // pending text to be read
+	line      []byte
+}
+
+func newAddrReader(p *Prefix, begin net.IP) *addrReader {
+	r := &addrReader{ipv4: p.IP.To4() != nil}
+	if begin == nil {
+		begin = p.IP
+	}
+	if !p.IPNet.Contains(begin) {
+		r.eof = true
+		return r
+	}
+	r.curr = ipToIPv6Int(begin.To16())
+	if r.ipv4 {
+		r.end = p.lastIPv4MappedIPv6Int()
+	} else {
+		r.end = p.lastIPv6Int()
+	}
+	return r
+}
+
+func (r *addrReader) Read(b []byte) (int, error) {
+	n := 0
+	for n < len(b) {
+		if len(r.buf) == 0 {
+			if r.eof {
+				break
+			}
+			ip := r.curr.ip()
+			if r.ipv4 {
+				ip = ip.To4()
+			}
+			r.line = append(appendIP(r.line[:0], ip), '\n')
+			r.buf = r.line
+			if r.curr.cmp(&r.end) == 0 {
+				r.eof = true
+			} else {
+				r.curr.incr()
+			}
+		}
+		m := copy(b[n:], r.buf)
+		r.buf = r.buf[m:]
+		n += m
+	}
+	if n == 0 && len(b) > 0 {
+		return 0, io.EOF
+	}
+	return n, nil
+}
@@ -0,0 +1,85 @@
+// Copyright 2013 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.
+
+package ipaddr
+
+import "sort"
+
+// A Conflict represents a pair of overlapping prefixes.
+type Conflict struct {
+	A, B     Prefix
+	Relation Relation // relationship of A to B
+}
+
+// A Relation represents a relationship between two prefixes.
+//
+// Two distinct IP address prefixes never partially overlap; they are
+// either disjoint or one contains the other.
+type Relation int
+
+const (
+	RelationDisjoint    Relation = iota // no address in common
+	RelationEqual                       // a and b are the same prefix
+	RelationContains                    // a contains b
+	RelationContainedBy                 // a is contained by b
+)
+
+var relations = [...]string{
+	RelationDisjoint:    "disjoint",
+	RelationEqual:       "equal",
+	RelationContains:    "contains",
+	RelationContainedBy: "contained-by",
+}
+
+func (r Relation) String() string {
+	if r < 0 || int(r) >= len(relations) {
+		return "unknown"
+	}
+	return relations[r]
+}
+
+// Relate returns the relationship between a and b.
+// It returns RelationDisjoint when a and b belong to different
+// address families.
+func Relate(a, b *Prefix) Relation {
+	if a.Equal(b) {
+		return RelationEqual
+	}
+	if a.Contains(b) {
+		return RelationContains
+	}
+	if b.Contains(a) {
+		return RelationContainedBy
+	}
+	return RelationDisjoint
+}
+
+// ConflictReport returns a list of all the pairs of overlapping
+// prefixes in ps.
+// A in each conflict always equals or contains B, and the conflicts
+// are sorted in ascending order of B.
+func ConflictReport(ps []Prefix) []Conflict {
+	sorted := make([]Prefix, len(ps))
+	copy(sorted, ps)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return compareAscending(&sorted[i], &sorted[j]) < 0
+	})
+	var cs []Conflict
+	var s4, s6 []*Prefix
+	for i := range sorted {
+		p := &sorted[i]
+		stack := &s6
+		if p.IP.To4() != nil {
+			stack = &s4
+		}
+		for len(*stack) > 0 && !(*stack)[len(*stack)-1].Overlaps(p) {
+			*stack = (*stack)[:len(*stack)-1]
+		}
+		for _, q := range *stack {
+			cs = append(cs, Conflict{A: *q, B: *p, Relation: Relate(q, p)})
+		}
+		*stack = append(*stack, p)
+	}
+	return cs
+}
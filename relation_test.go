@@ -0,0 +1,70 @@
+// Copyright 2013 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.
+
+package ipaddr_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/mikioh/ipaddr"
+)
+
+func TestConflictReport(t *testing.T) {
+	for i, tt := range []struct {
+		in   []string
+		want []ipaddr.Conflict
+	}{
+		{[]string{"192.0.2.0/25", "192.0.2.128/25", "2001:db8::/32"}, nil},
+		{
+			[]string{"192.0.2.128/25", "192.0.2.0/24", "2001:db8:1::/48", "192.0.2.0/26", "198.51.100.0/24", "2001:db8::/32", "192.0.2.0/24"},
+			[]ipaddr.Conflict{
+				{A: *toPrefix("192.0.2.0/24"), B: *toPrefix("192.0.2.0/24"), Relation: ipaddr.RelationEqual},
+				{A: *toPrefix("192.0.2.0/24"), B: *toPrefix("192.0.2.0/26"), Relation: ipaddr.RelationContains},
+				{A: *toPrefix("192.0.2.0/24"), B: *toPrefix("192.0.2.0/26"), Relation: ipaddr.RelationContains},
+				{A: *toPrefix("192.0.2.0/24"), B: *toPrefix("192.0.2.128/25"), Relation: ipaddr.RelationContains},
+				{A: *toPrefix("192.0.2.0/24"), B: *toPrefix("192.0.2.128/25"), Relation: ipaddr.RelationContains},
+				{A: *toPrefix("2001:db8::/32"), B: *toPrefix("2001:db8:1::/48"), Relation: ipaddr.RelationContains},
+			},
+		},
+		{
+			[]string{"0.0.0.0/0", "::/0", "10.0.0.0/8", "2001:db8::/32"},
+			[]ipaddr.Conflict{
+				{A: *toPrefix("0.0.0.0/0"), B: *toPrefix("10.0.0.0/8"), Relation: ipaddr.RelationContains},
+				{A: *toPrefix("::/0"), B: *toPrefix("2001:db8::/32"), Relation: ipaddr.RelationContains},
+			},
+		},
+	} {
+		cs := ipaddr.ConflictReport(toPrefixes(tt.in))
+		if !reflect.DeepEqual(cs, tt.want) {
+			t.Errorf("#%d: got %v; want %v", i, cs, tt.want)
+		}
+	}
+}
+
+func TestRelate(t *testing.T) {
+	for i, tt := range []struct {
+		a, b string
+		want ipaddr.Relation
+	}{
+		{"192.0.2.0/24", "198.51.100.0/24", ipaddr.RelationDisjoint},
+		{"192.0.2.0/25", "192.0.2.128/25", ipaddr.RelationDisjoint},
+		{"192.0.2.0/24", "192.0.2.0/24", ipaddr.RelationEqual},
+		{"192.0.2.0/23", "192.0.2.0/24", ipaddr.RelationContains},
+		{"192.0.2.128/25", "192.0.2.0/24", ipaddr.RelationContainedBy},
+
+		{"2001:db8:1::/48", "2001:db8:2::/48", ipaddr.RelationDisjoint},
+		{"2001:db8:1::/48", "2001:db8:1::/48", ipaddr.RelationEqual},
+		{"2001:db8::/32", "2001:db8:1::/48", ipaddr.RelationContains},
+		{"2001:db8:1::/127", "2001:db8:1::/126", ipaddr.RelationContainedBy},
+
+		{"0.0.0.0/0", "::/0", ipaddr.RelationDisjoint},
+		{"2001:db8::/32", "192.0.2.0/24", ipaddr.RelationDisjoint},
+	} {
+		a, b := toPrefix(tt.a), toPrefix(tt.b)
+		if r := ipaddr.Relate(a, b); r != tt.want {
+			t.Errorf("#%d: got %v; want %v", i, r, tt.want)
+		}
+	}
+}
@@ -0,0 +1,165 @@
+// Copyright 2013 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.
+
+package ipaddr
+
+import (
+	"math/big"
+	"net"
+)
+
+// A ReservedPrefix represents an IP address prefix that reserves
+// some of the leading and trailing host-assignable addresses, such as
+// an address for the default gateway.
+//
+// The methods of ReservedPrefix that enumerate or return
+// host-assignable addresses exclude the reserved addresses; the other
+// methods promoted from Prefix ignore the reservation.
+type ReservedPrefix struct {
+	Prefix
+	ReserveFirst int // number of reserved leading host-assignable addresses
+	ReserveLast  int // number of reserved trailing host-assignable addresses
+}
+
+// bounds returns the first and last host-assignable addresses in p
+// that are not reserved.
+// It returns false when no such address exists.
+func (p *ReservedPrefix) bounds() (first, last ipv6Int, ok bool) {
+	if p.ReserveFirst < 0 || p.ReserveLast < 0 {
+		return first, last, false
+	}
+	i, j := ipToInt(p.Prefix.FirstHost()), ipToInt(p.Prefix.LastHost())
+	i.Add(i, big.NewInt(int64(p.ReserveFirst)))
+	j.Sub(j, big.NewInt(int64(p.ReserveLast)))
+	if i.Cmp(j) > 0 {
+		return first, last, false
+	}
+	z := p.bitLen()
+	return ipToIPv6Int(intToIP(i, z).To16()), ipToIPv6Int(intToIP(j, z).To16()), true
+}
+
+// clamp returns begin, or the first address in p that is not
+// reserved when begin is nil or precedes it, and the last address in
+// p that is not reserved.
+// It returns false when no such address exists or begin is not in p.
+func (p *ReservedPrefix) clamp(begin net.IP) (net.IP, ipv6Int, bool) {
+	first, last, ok := p.bounds()
+	if !ok || begin != nil && !p.IPNet.Contains(begin) {
+		return nil, last, false
+	}
+	if begin != nil {
+		if i := ipToIPv6Int(begin.To16()); i.cmp(&first) > 0 {
+			return begin, last, true
+		}
+	}
+	return first.ip(), last, true
+}
+
+// FirstHost returns the first host-assignable IP address in p that is
+// not reserved.
+// It returns nil when all the host-assignable addresses are reserved.
+func (p *ReservedPrefix) FirstHost() net.IP {
+	first, _, ok := p.bounds()
+	if !ok {
+		return nil
+	}
+	return first.ip()
+}
+
+// HostsExcluding is like HostsExcluding of Prefix but also excludes
+// the reserved addresses of p.
+func (p *ReservedPrefix) HostsExcluding(begin net.IP, reserved []net.IP) []net.IP {
+	begin, last, ok := p.clamp(begin)
+	if !ok {
+		return nil
+	}
+	return trim(p.Prefix.HostsExcluding(begin, reserved), &last)
+}
+
+// HostsFunc is like HostsFunc of Prefix but also excludes the
+// reserved addresses of p.
+func (p *ReservedPrefix) HostsFunc(begin net.IP, fn func(net.IP) bool) {
+	begin, last, ok := p.clamp(begin)
+	if !ok {
+		return
+	}
+	p.Prefix.HostsFunc(begin, func(ip net.IP) bool {
+		if i := ipToIPv6Int(ip.To16()); i.cmp(&last) > 0 {
+			return false
+		}
+		return fn(ip)
+	})
+}
+
+// HostsMatching is like HostsMatching of Prefix but also excludes
+// the reserved addresses of p.
+func (p *ReservedPrefix) HostsMatching(begin net.IP, pos, nbits int, value uint32) []net.IP {
+	begin, last, ok := p.clamp(begin)
+	if !ok {
+		return nil
+	}
+	return trim(p.Prefix.HostsMatching(begin, pos, nbits, value), &last)
+}
+
+// HostsStrict is like HostsStrict of Prefix but also excludes the
+// reserved addresses of p.
+func (p *ReservedPrefix) HostsStrict(begin net.IP) []net.IP {
+	begin, last, ok := p.clamp(begin)
+	if !ok {
+		return nil
+	}
+	return trim(p.Prefix.HostsStrict(begin), &last)
+}
+
+// LastHost returns the last host-assignable IP address in p that is
+// not reserved.
+// It returns nil when all the host-assignable addresses are reserved.
+func (p *ReservedPrefix) LastHost() net.IP {
+	_, last, ok := p.bounds()
+	if !ok {
+		return nil
+	}
+	return last.ip()
+}
+
+// Summary is like Summary of Prefix but returns the first and last
+// host-assignable addresses in p that are not reserved.
+// The first and last addresses are nil when all the host-assignable
+// addresses are reserved.
+func (p *ReservedPrefix) Summary() (network, first, last, broadcast net.IP) {
+	network, _, _, broadcast = p.Prefix.Summary()
+	if i, j, ok := p.bounds(); ok {
+		first, last = i.ip(), j.ip()
+	}
+	return
+}
+
+// NewPrefixReserve returns a new prefix of ip and the prefix length
+// nbits, which reserves reserveFirst leading and reserveLast trailing
+// host-assignable addresses.
+// It returns nil when ip or nbits is invalid.
+func NewPrefixReserve(ip net.IP, nbits int, reserveFirst, reserveLast int) *ReservedPrefix {
+	z := IPv6PrefixLen
+	if ip.To4() != nil {
+		z = IPv4PrefixLen
+	}
+	if ip.To16() == nil || nbits < 0 || nbits > z {
+		return nil
+	}
+	return &ReservedPrefix{Prefix: *ipToPrefix(ip, nbits, z), ReserveFirst: reserveFirst, ReserveLast: reserveLast}
+}
+
+// trim returns the leading addresses in ips, a list of addresses in
+// ascending order, that do not exceed last.
+func trim(ips []net.IP, last *ipv6Int) []net.IP {
+	for n, ip := range ips {
+		if i := ipToIPv6Int(ip.To16()); i.cmp(last) > 0 {
+			if n == 0 {
+				return nil
+			}
+			return ips[:n]
+		}
+	}
+	return ips
+}
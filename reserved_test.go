@@ -0,0 +1,95 @@
+// Copyright 2013 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.
+
+package ipaddr_test
+
+import (
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/mikioh/ipaddr"
+)
+
+func TestReservedPrefix(t *testing.T) {
+	for i, tt := range []struct {
+		ip                        net.IP
+		nbits                     int
+		reserveFirst, reserveLast int
+		first, last               net.IP
+		hosts                     []net.IP
+	}{
+		{
+			net.ParseIP("192.0.2.0"), 29, 1, 0,
+			net.ParseIP("192.0.2.2"), net.ParseIP("192.0.2.6"),
+			[]net.IP{net.ParseIP("192.0.2.2"), net.ParseIP("192.0.2.3"), net.ParseIP("192.0.2.4"), net.ParseIP("192.0.2.5"), net.ParseIP("192.0.2.6")},
+		},
+		{
+			net.ParseIP("192.0.2.0"), 29, 2, 2,
+			net.ParseIP("192.0.2.3"), net.ParseIP("192.0.2.4"),
+			[]net.IP{net.ParseIP("192.0.2.3"), net.ParseIP("192.0.2.4")},
+		},
+		{
+			net.ParseIP("192.0.2.0"), 29, 3, 3,
+			nil, nil,
+			nil,
+		},
+		{
+			net.ParseIP("192.0.2.0"), 31, 0, 1,
+			net.ParseIP("192.0.2.0"), net.ParseIP("192.0.2.0"),
+			[]net.IP{net.ParseIP("192.0.2.0")},
+		},
+
+		{
+			net.ParseIP("2001:db8::"), 125, 1, 1,
+			net.ParseIP("2001:db8::2"), net.ParseIP("2001:db8::6"),
+			[]net.IP{net.ParseIP("2001:db8::2"), net.ParseIP("2001:db8::3"), net.ParseIP("2001:db8::4"), net.ParseIP("2001:db8::5"), net.ParseIP("2001:db8::6")},
+		},
+	} {
+		p := ipaddr.NewPrefixReserve(tt.ip, tt.nbits, tt.reserveFirst, tt.reserveLast)
+		if ip := p.FirstHost(); !ip.Equal(tt.first) {
+			t.Errorf("#%d: got %v; want %v", i, ip, tt.first)
+		}
+		if ip := p.LastHost(); !ip.Equal(tt.last) {
+			t.Errorf("#%d: got %v; want %v", i, ip, tt.last)
+		}
+		if ips := p.HostsExcluding(nil, nil); !reflect.DeepEqual(ips, tt.hosts) {
+			t.Errorf("#%d: got %v; want %v", i, ips, tt.hosts)
+		}
+		if ips := p.HostsStrict(nil); !reflect.DeepEqual(ips, tt.hosts) {
+			t.Errorf("#%d: got %v; want %v", i, ips, tt.hosts)
+		}
+		var ips []net.IP
+		p.HostsFunc(nil, func(ip net.IP) bool {
+			ips = append(ips, ip)
+			return true
+		})
+		if !reflect.DeepEqual(ips, tt.hosts) {
+			t.Errorf("#%d: got %v; want %v", i, ips, tt.hosts)
+		}
+		if _, first, last, _ := p.Summary(); !first.Equal(tt.first) || !last.Equal(tt.last) {
+			t.Errorf("#%d: got %v, %v; want %v, %v", i, first, last, tt.first, tt.last)
+		}
+	}
+
+	p := ipaddr.NewPrefixReserve(net.ParseIP("2001:db8::"), 64, 1<<40, 1<<40)
+	if ip, want := p.FirstHost(), net.ParseIP("2001:db8::100:0:1"); !ip.Equal(want) {
+		t.Errorf("got %v; want %v", ip, want)
+	}
+	if ip, want := p.LastHost(), net.ParseIP("2001:db8::ffff:feff:ffff:ffff"); !ip.Equal(want) {
+		t.Errorf("got %v; want %v", ip, want)
+	}
+	if ips := p.HostsExcluding(nil, nil); len(ips) != 1<<17 {
+		t.Errorf("got %v; want %v", len(ips), 1<<17)
+	} else if want := net.ParseIP("2001:db8::100:0:1"); !ips[0].Equal(want) {
+		t.Errorf("got %v; want %v", ips[0], want)
+	}
+	if ips, want := p.HostsMatching(net.ParseIP("2001:db8::ffff:feff:ffff:fff0"), 124, 4, 0xf), []net.IP{net.ParseIP("2001:db8::ffff:feff:ffff:ffff")}; !reflect.DeepEqual(ips, want) {
+		t.Errorf("got %v; want %v", ips, want)
+	}
+
+	if p := ipaddr.NewPrefixReserve(net.ParseIP("192.0.2.0"), 33, 0, 0); p != nil {
+		t.Errorf("got %v; want nil", p)
+	}
+}
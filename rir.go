@@ -0,0 +1,97 @@
+// Copyright 2013 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.
+
+package ipaddr
+
+import (
+	"net"
+	"strconv"
+)
+
+// rirBlocks is a table of top-level IP address blocks allocated to the
+// Regional Internet Registries by the IANA.
+// An IPv4 block is represented by the first octet of a /8 block.
+var rirBlocks = []struct {
+	name string
+	ipv4 []byte
+	ipv6 []string
+}{
+	{
+		"AFRINIC",
+		[]byte{41, 102, 105, 154, 196, 197},
+		[]string{"2c00::/12"},
+	},
+	{
+		"APNIC",
+		[]byte{
+			1, 14, 27, 36, 39, 42, 43, 49, 58, 59, 60, 61, 101, 103, 106,
+			110, 111, 112, 113, 114, 115, 116, 117, 118, 119, 120, 121, 122, 123, 124, 125, 126,
+			175, 180, 182, 183, 202, 203, 210, 211, 218, 219, 220, 221, 222, 223,
+		},
+		[]string{"2400::/12"},
+	},
+	{
+		"ARIN",
+		[]byte{
+			23, 24, 50, 63, 64, 65, 66, 67, 68, 69, 70, 71, 72, 73, 74, 75, 76,
+			96, 97, 98, 99, 100, 104, 107, 108, 173, 174, 184, 199,
+			204, 205, 206, 207, 208, 209, 216,
+		},
+		[]string{"2600::/12"},
+	},
+	{
+		"LACNIC",
+		[]byte{177, 179, 181, 186, 187, 189, 190, 191, 200, 201},
+		[]string{"2800::/12"},
+	},
+	{
+		"RIPE",
+		[]byte{
+			2, 5, 31, 37, 46, 62, 77, 78, 79, 80, 81, 82, 83, 84, 85, 86, 87, 88, 89, 90, 91, 92, 93, 94, 95,
+			109, 176, 178, 185, 188, 193, 194, 195, 212, 213, 217,
+		},
+		[]string{"2a00::/12"},
+	},
+}
+
+type rirPrefix struct {
+	Prefix
+	name string
+}
+
+var rirPrefixes = newRIRPrefixes()
+
+func newRIRPrefixes() []rirPrefix {
+	var ps []rirPrefix
+	for _, b := range rirBlocks {
+		for _, o := range b.ipv4 {
+			_, n, err := net.ParseCIDR(strconv.Itoa(int(o)) + ".0.0.0/8")
+			if err != nil {
+				panic(err)
+			}
+			ps = append(ps, rirPrefix{Prefix: *NewPrefix(n), name: b.name})
+		}
+		for _, s := range b.ipv6 {
+			_, n, err := net.ParseCIDR(s)
+			if err != nil {
+				panic(err)
+			}
+			ps = append(ps, rirPrefix{Prefix: *NewPrefix(n), name: b.name})
+		}
+	}
+	return ps
+}
+
+// RIR returns the name of the Regional Internet Registry to which the
+// IANA allocated the address block containing p.
+// It returns "AFRINIC", "APNIC", "ARIN", "LACNIC" or "RIPE", or the
+// empty string when p is not within such a block.
+func RIR(p *Prefix) string {
+	for i := range rirPrefixes {
+		if rirPrefixes[i].Equal(p) || rirPrefixes[i].Contains(p) {
+			return rirPrefixes[i].name
+		}
+	}
+	return ""
+}
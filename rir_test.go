@@ -0,0 +1,41 @@
+// Copyright 2013 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.
+
+package ipaddr_test
+
+import (
+	"testing"
+
+	"github.com/mikioh/ipaddr"
+)
+
+func TestRIR(t *testing.T) {
+	for i, tt := range []struct {
+		in   string
+		want string
+	}{
+		{"41.0.0.0/11", "AFRINIC"},
+		{"1.1.1.0/24", "APNIC"},
+		{"23.0.0.0/8", "ARIN"},
+		{"64.0.0.0/10", "ARIN"},
+		{"200.0.0.0/16", "LACNIC"},
+		{"193.0.0.0/21", "RIPE"},
+		{"10.0.0.0/8", ""},
+		{"192.0.2.0/24", ""},
+		{"0.0.0.0/0", ""},
+
+		{"2c0f:f000::/20", "AFRINIC"},
+		{"2400:cb00::/32", "APNIC"},
+		{"2600::/12", "ARIN"},
+		{"2800:100::/24", "LACNIC"},
+		{"2a00:1450::/32", "RIPE"},
+		{"2001:db8::/32", ""},
+		{"::/0", ""},
+	} {
+		p := toPrefix(tt.in)
+		if s := ipaddr.RIR(p); s != tt.want {
+			t.Errorf("#%d: got %v; want %v", i, s, tt.want)
+		}
+	}
+}
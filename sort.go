@@ -93,6 +93,27 @@ func newSortedPrefixes(ps []Prefix, dir sortDir, strict bool) []Prefix {
 	return nps
 }
 
+// newDisjointPrefixes returns a sorted list of prefixes that are
+// copied from ps, except the prefixes contained by any other prefix in
+// ps.
+func newDisjointPrefixes(ps []Prefix) []Prefix {
+	ps = newSortedPrefixes(ps, sortAscending, false)
+	nps := ps[:0]
+	var p4, p6 *Prefix // last appended prefixes for each address family
+	for i := range ps {
+		p := &p6
+		if ps[i].IP.To4() != nil {
+			p = &p4
+		}
+		if *p != nil && (*p).Contains(&ps[i]) {
+			continue
+		}
+		nps = append(nps, ps[i])
+		*p = &nps[len(nps)-1]
+	}
+	return nps
+}
+
 func clonePrefix(s *Prefix) *Prefix {
 	d := &Prefix{IPNet: net.IPNet{IP: make(net.IP, net.IPv6len), Mask: make(net.IPMask, len(s.Mask))}}
 	copy(d.IP, s.IP.To16())